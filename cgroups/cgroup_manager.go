@@ -1,41 +1,108 @@
 package cgroups
 
 import (
+	"fmt"
+	"io/ioutil"
 	"lumper/cgroups/subsystems"
 	log "github.com/sirupsen/logrus"
+	"os"
+	"path"
+	"strconv"
+	"strings"
 )
 
-type CgroupManager struct {
+type CgroupManager interface {
+	// 设置 Cgroup 资源限制
+	Set(res *subsystems.ResourceConfig) error
+	// 将进程加入到 Cgroup 中
+	Apply(pid int) error
+	// 获取 Cgroup 在文件系统中的绝对路径
+	Paths() map[string]string
+	// 释放 Cgroup
+	Destroy() error
+	// 获取 Cgroup 中因内存不足被杀死的进程数
+	OOMKillCount() (uint64, error)
+	// 冻结 Cgroup 中的所有进程
+	Freeze() error
+	// 解冻 Cgroup 中的所有进程
+	Thaw() error
+	// 获取 Cgroup 的资源使用情况
+	GetStats() (*Stats, error)
+	// 获取 Cgroup 中所有进程的 PID
+	GetPids() ([]int, error)
+}
+
+// 根据宿主机的 hierarchy 模式创建 Cgroup Manager
+func NewCgroupManager(path string) CgroupManager  {
+	if IsCgroup2UnifiedMode() {
+		return NewCgroupManagerV2(path)
+	}
+	return NewCgroupManagerV1(path)
+}
+
+// cgroup v1 中每个 Subsystem 单独挂载 hierarchy
+type CgroupManagerV1 struct {
 	// Cgroup 在 hierarchy 中的路径
 	Path string
 	// 资源配置
 	Resource *subsystems.ResourceConfig
 }
 
-func NewCgroupManager(path string) *CgroupManager  {
-	return &CgroupManager{
+func NewCgroupManagerV1(path string) *CgroupManagerV1  {
+	return &CgroupManagerV1{
 		Path:     path,
 	}
 }
 
 // 设置各个 Subsystem 挂载中的 Cgroup 资源限制
-func (c *CgroupManager) Set(res *subsystems.ResourceConfig) error {
+func (c *CgroupManagerV1) Set(res *subsystems.ResourceConfig) error {
+	c.Resource = res
+	var errs []string
 	for _, subSysIns := range(subsystems.SubsystemsIns) {
-		subSysIns.Set(c.Path, res)
+		// 跳过宿主机上没有挂载的 Subsystem，设置了相应的限制时报错，避免容器在没有限制的情况下运行
+		if subsystems.FindCgroupMountPoint(subSysIns.Name()) == "" {
+			if res.Requests(subSysIns.Name()) {
+				errs = append(errs, fmt.Sprintf("%s: cgroup controller is not mounted", subSysIns.Name()))
+			}
+			continue
+		}
+		if err := subSysIns.Set(c.Path, res); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", subSysIns.Name(), err))
+		}
 	}
-	return nil
+	return joinErrors(errs)
 }
 
 // 将进程 PID 加入到每个 Cgroup 中
-func (c *CgroupManager) Apply(pid int) error {
+func (c *CgroupManagerV1) Apply(pid int) error {
+	var errs []string
+	for _, subSysIns := range(subsystems.SubsystemsIns) {
+		if subsystems.FindCgroupMountPoint(subSysIns.Name()) == "" {
+			if c.Resource.Requests(subSysIns.Name()) {
+				errs = append(errs, fmt.Sprintf("%s: cgroup controller is not mounted", subSysIns.Name()))
+			}
+			continue
+		}
+		if err := subSysIns.Apply(c.Path, pid); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", subSysIns.Name(), err))
+		}
+	}
+	return joinErrors(errs)
+}
+
+// 获取 Cgroup 在各个 Subsystem 挂载中的绝对路径
+func (c *CgroupManagerV1) Paths() map[string]string {
+	paths := map[string]string{}
 	for _, subSysIns := range(subsystems.SubsystemsIns) {
-		subSysIns.Apply(c.Path, pid)
+		if cgroupRoot := subsystems.FindCgroupMountPoint(subSysIns.Name()); cgroupRoot != "" {
+			paths[subSysIns.Name()] = path.Join(cgroupRoot, c.Path)
+		}
 	}
-	return nil
+	return paths
 }
 
 // 释放各个 Subsystem 挂载中的 Cgroup
-func (c *CgroupManager) Destroy() error {
+func (c *CgroupManagerV1) Destroy() error {
 	for _, SubSysIns := range(subsystems.SubsystemsIns) {
 		if err := SubSysIns.Remove(c.Path); err != nil {
 			log.Warnf("remove cgroup fail %v", err)
@@ -44,3 +111,59 @@ func (c *CgroupManager) Destroy() error {
 	return nil
 }
 
+// 通过 freezer Subsystem 冻结进程
+func (c *CgroupManagerV1) Freeze() error {
+	freezer := &subsystems.FreezerSubSystem{}
+	return freezer.Freeze(c.Path, subsystems.FreezerFrozen)
+}
+
+func (c *CgroupManagerV1) Thaw() error {
+	freezer := &subsystems.FreezerSubSystem{}
+	return freezer.Freeze(c.Path, subsystems.FreezerThawed)
+}
+
+// 各个 Subsystem 中的进程相同，从第一个存在的 Cgroup 中读取
+func (c *CgroupManagerV1) GetPids() ([]int, error) {
+	for _, subSysIns := range(subsystems.SubsystemsIns) {
+		cgroupRoot := subsystems.FindCgroupMountPoint(subSysIns.Name())
+		if cgroupRoot == "" {
+			continue
+		}
+		procs := path.Join(cgroupRoot, c.Path, "cgroup.procs")
+		if _, err := os.Stat(procs); err == nil {
+			return readPids(procs)
+		}
+	}
+	return nil, fmt.Errorf("cgroup %s not found", c.Path)
+}
+
+// 读取 cgroup.procs 中每行一个的 PID
+func readPids(procs string) ([]int, error) {
+	content, err := ioutil.ReadFile(procs)
+	if err != nil {
+		return nil, err
+	}
+	var pids []int
+	for _, line := range strings.Fields(string(content)) {
+		pid, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pid %s in %s", line, procs)
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
+
+// 将各个 Subsystem 的错误合并成一个
+func joinErrors(errs []string) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(errs, "; "))
+}
+
+// 从 memory Subsystem 的 memory.oom_control 中读取 OOM kill 计数
+func (c *CgroupManagerV1) OOMKillCount() (uint64, error) {
+	memory := &subsystems.MemorySubSystem{}
+	return memory.OOMKillCount(c.Path)
+}
@@ -0,0 +1,371 @@
+package cgroups
+
+import (
+	"bufio"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+	"io/ioutil"
+	"lumper/cgroups/subsystems"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cgroup v2 默认挂载点
+const defaultCgroup2MountPoint = "/sys/fs/cgroup"
+
+// 等待 Cgroup 中所有进程冻结的时间
+const freezeTimeout = 10 * time.Second
+
+// 判断宿主机是否只挂载了 cgroup v2 统一 hierarchy
+func IsCgroup2UnifiedMode() bool {
+	var st unix.Statfs_t
+	if err := unix.Statfs(defaultCgroup2MountPoint, &st); err != nil {
+		return false
+	}
+	return st.Type == unix.CGROUP2_SUPER_MAGIC
+}
+
+// 寻找 cgroup v2 的挂载点
+func FindCgroup2MountPoint() string {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return defaultCgroup2MountPoint
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 分隔符 "-" 之后的第一列为文件系统类型
+		fields := strings.Split(scanner.Text(), " - ")
+		if len(fields) == 2 && strings.HasPrefix(fields[1], "cgroup2 ") {
+			return strings.Split(fields[0], " ")[4]
+		}
+	}
+	return defaultCgroup2MountPoint
+}
+
+// cgroup v2 中所有控制器共用一个 hierarchy
+type CgroupManagerV2 struct {
+	// Cgroup 在 hierarchy 中的路径
+	Path string
+	// hierarchy 的挂载点
+	Root string
+}
+
+func NewCgroupManagerV2(path string) *CgroupManagerV2 {
+	return &CgroupManagerV2{
+		Path: path,
+		Root: FindCgroup2MountPoint(),
+	}
+}
+
+// Cgroup 在文件系统中的绝对路径
+func (c *CgroupManagerV2) fullPath() string {
+	return path.Join(c.Root, c.Path)
+}
+
+// 创建 Cgroup 并设置资源限制
+func (c *CgroupManagerV2) Set(res *subsystems.ResourceConfig) error {
+	if err := c.create(requiredControllers(res)); err != nil {
+		return err
+	}
+	if res == nil {
+		return nil
+	}
+	// 继续设置其他限制，最后一起返回错误
+	var errs []string
+	if res.CpuShare != "" {
+		if shares, err := strconv.ParseUint(res.CpuShare, 10, 64); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid cpu share %s", res.CpuShare))
+		} else if err := c.writeFile("cpu.weight", strconv.FormatUint(cpuSharesToWeight(shares), 10)); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if res.CpuQuota != "" || res.CpuPeriod != "" {
+		if err := c.writeFile("cpu.max", cpuMax(res.CpuQuota, res.CpuPeriod)); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if res.CpuSetMems != "" {
+		if err := c.writeFile("cpuset.mems", res.CpuSetMems); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if res.CpuSet != "" {
+		if err := c.writeFile("cpuset.cpus", res.CpuSet); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if res.MemoryLimit != "" {
+		if err := c.writeFile("memory.max", res.MemoryLimit); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if res.MemorySwap != "" {
+		if swapMax, err := memorySwapMax(res.MemoryLimit, res.MemorySwap); err != nil {
+			errs = append(errs, err.Error())
+		} else if err := c.writeFile("memory.swap.max", swapMax); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if res.MemoryReservation != "" {
+		if err := c.writeFile("memory.low", res.MemoryReservation); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if res.KernelMemory != "" {
+		errs = append(errs, "kernel memory limit is not supported on cgroup v2")
+	}
+	if res.OomKillDisable {
+		errs = append(errs, "disabling oom kill is not supported on cgroup v2")
+	}
+	if res.BlkioWeight != "" {
+		if weight, err := strconv.ParseUint(res.BlkioWeight, 10, 64); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid blkio weight %s", res.BlkioWeight))
+		} else if err := c.writeFile("io.weight", fmt.Sprintf("default %d", blkioWeightToIOWeight(weight))); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	for _, ioMax := range ioMaxLines(res) {
+		if err := c.writeFile("io.max", ioMax); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if res.PidsLimit != "" {
+		if err := c.writeFile("pids.max", subsystems.PidsMax(res.PidsLimit)); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	// cgroup v2 没有 devices 控制器，通过 BPF 程序实现设备白名单
+	if len(res.Devices) > 0 {
+		if err := attachDeviceFilter(c.fullPath(), res.Devices); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	return joinErrors(errs)
+}
+
+// 将进程 PID 加入到 Cgroup 中
+func (c *CgroupManagerV2) Apply(pid int) error {
+	if err := c.create(nil); err != nil {
+		return err
+	}
+	return c.writeFile("cgroup.procs", strconv.Itoa(pid))
+}
+
+// 获取 Cgroup 在文件系统中的绝对路径
+func (c *CgroupManagerV2) Paths() map[string]string {
+	return map[string]string{"unified": c.fullPath()}
+}
+
+// 释放 Cgroup，cgroup v2 中只能删除不包含进程的空目录
+func (c *CgroupManagerV2) Destroy() error {
+	if err := os.Remove(c.fullPath()); err != nil && !os.IsNotExist(err) {
+		log.Warnf("remove cgroup fail %v", err)
+		return err
+	}
+	return nil
+}
+
+// 从 memory.events 中读取 OOM kill 计数
+func (c *CgroupManagerV2) OOMKillCount() (uint64, error) {
+	return subsystems.ReadKeyedValue(path.Join(c.fullPath(), "memory.events"), "oom_kill")
+}
+
+// 读取 cgroup.procs 中的进程
+func (c *CgroupManagerV2) GetPids() ([]int, error) {
+	return readPids(path.Join(c.fullPath(), "cgroup.procs"))
+}
+
+// 通过 cgroup.freeze 冻结进程
+func (c *CgroupManagerV2) Freeze() error {
+	return c.freeze("1")
+}
+
+func (c *CgroupManagerV2) Thaw() error {
+	return c.freeze("0")
+}
+
+// 写入 cgroup.freeze 后等待 cgroup.events 中的 frozen 与之一致
+func (c *CgroupManagerV2) freeze(state string) error {
+	if err := c.writeFile("cgroup.freeze", state); err != nil {
+		return err
+	}
+	want, _ := strconv.ParseUint(state, 10, 64)
+	deadline := time.Now().Add(freezeTimeout)
+	for {
+		frozen, err := subsystems.ReadKeyedValue(path.Join(c.fullPath(), "cgroup.events"), "frozen")
+		if err != nil {
+			return err
+		}
+		if frozen == want {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timeout waiting for cgroup freeze state %s", state)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// 创建 Cgroup，并在各级父 Cgroup 中启用子 Cgroup 需要的控制器
+func (c *CgroupManagerV2) create(required []string) error {
+	current := c.Root
+	for _, dir := range strings.Split(strings.Trim(path.Clean(c.Path), "/"), "/") {
+		if err := enableControllers(current, required); err != nil {
+			return err
+		}
+		current = path.Join(current, dir)
+		if err := os.Mkdir(current, 0755); err != nil && !os.IsExist(err) {
+			return fmt.Errorf("error create cgroup %v", err)
+		}
+	}
+	return nil
+}
+
+// 资源限制需要的控制器，cgroup v1 的 blkio 对应 cgroup v2 的 io
+func requiredControllers(res *subsystems.ResourceConfig) []string {
+	var controllers []string
+	for _, name := range []string{"cpu", "cpuset", "memory", "pids", "blkio"} {
+		if !res.Requests(name) {
+			continue
+		}
+		if name == "blkio" {
+			name = "io"
+		}
+		controllers = append(controllers, name)
+	}
+	return controllers
+}
+
+// 在 cgroup.subtree_control 中逐个启用控制器，使子 Cgroup 可以使用
+// 一次写入多个控制器时任意一个失败都会导致全部失败，如存在实时线程时无法启用 cpu
+// 资源限制需要的控制器启用失败时报错，其他可用的控制器只用于统计，启用失败时忽略
+func enableControllers(cgroupPath string, required []string) error {
+	content, err := ioutil.ReadFile(path.Join(cgroupPath, "cgroup.controllers"))
+	if err != nil {
+		return fmt.Errorf("read cgroup controllers error %v", err)
+	}
+	available := map[string]bool{}
+	for _, controller := range strings.Fields(string(content)) {
+		available[controller] = true
+	}
+	enabled := map[string]bool{}
+	if content, err := ioutil.ReadFile(path.Join(cgroupPath, "cgroup.subtree_control")); err == nil {
+		for _, controller := range strings.Fields(string(content)) {
+			enabled[controller] = true
+		}
+	}
+	isRequired := map[string]bool{}
+	for _, controller := range required {
+		isRequired[controller] = true
+		if !available[controller] {
+			return fmt.Errorf("cgroup controller %s is not available in %s", controller, cgroupPath)
+		}
+	}
+
+	subtreeControl := path.Join(cgroupPath, "cgroup.subtree_control")
+	for _, controller := range strings.Fields(string(content)) {
+		if enabled[controller] {
+			continue
+		}
+		if err := ioutil.WriteFile(subtreeControl, []byte("+" + controller), 0644); err != nil {
+			if isRequired[controller] {
+				return fmt.Errorf("enable controller %s in %s error %v", controller, cgroupPath, err)
+			}
+			log.Debugf("enable controller %s in %s error %v", controller, cgroupPath, err)
+		}
+	}
+	return nil
+}
+
+func (c *CgroupManagerV2) writeFile(name, value string) error {
+	if err := ioutil.WriteFile(path.Join(c.fullPath(), name), []byte(value), 0644); err != nil {
+		return fmt.Errorf("set cgroup %s fail %v", name, err)
+	}
+	return nil
+}
+
+// 生成 cpu.max 的内容，格式为 "$MAX $PERIOD"，配额为 -1 或未设置时为 max
+func cpuMax(quota, period string) string {
+	if quota == "" || quota == "-1" {
+		quota = "max"
+	}
+	if period == "" {
+		period = strconv.Itoa(subsystems.DefaultCpuPeriod)
+	}
+	return quota + " " + period
+}
+
+// cgroup v1 的 memsw 限制的是内存加 swap 的总量，cgroup v2 的 memory.swap.max 只限制 swap
+func memorySwapMax(memory, memorySwap string) (string, error) {
+	if memorySwap == "-1" {
+		return "max", nil
+	}
+	swap, err := strconv.ParseInt(memorySwap, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid memory swap %s", memorySwap)
+	}
+	limit, err := strconv.ParseInt(memory, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("memory swap requires a memory limit in bytes")
+	}
+	if swap < limit {
+		return "", fmt.Errorf("memory swap %d should be larger than memory limit %d", swap, limit)
+	}
+	return strconv.FormatInt(swap - limit, 10), nil
+}
+
+// 将 cgroup v1 的 blkio.weight [10, 1000] 转换成 cgroup v2 的 io.weight [1, 10000]
+func blkioWeightToIOWeight(weight uint64) uint64 {
+	if weight < 10 {
+		weight = 10
+	}
+	if weight > 1000 {
+		weight = 1000
+	}
+	return 1 + ((weight - 10) * 9999) / 990
+}
+
+// 将设备限制按设备合并成 io.max 的内容，格式为 "<major>:<minor> rbps=N wbps=N riops=N wiops=N"
+func ioMaxLines(res *subsystems.ResourceConfig) []string {
+	var devices []string
+	limits := map[string][]string{}
+	add := func(key string, throttles []string) {
+		for _, throttle := range throttles {
+			fields := strings.Fields(throttle)
+			if len(fields) != 2 {
+				continue
+			}
+			if _, ok := limits[fields[0]]; !ok {
+				devices = append(devices, fields[0])
+			}
+			limits[fields[0]] = append(limits[fields[0]], key + "=" + fields[1])
+		}
+	}
+	add("rbps", res.DeviceReadBps)
+	add("wbps", res.DeviceWriteBps)
+	add("riops", res.DeviceReadIOps)
+	add("wiops", res.DeviceWriteIOps)
+
+	var lines []string
+	for _, device := range devices {
+		lines = append(lines, device + " " + strings.Join(limits[device], " "))
+	}
+	return lines
+}
+
+// 将 cgroup v1 的 cpu.shares [2, 262144] 转换成 cgroup v2 的 cpu.weight [1, 10000]
+func cpuSharesToWeight(shares uint64) uint64 {
+	if shares < 2 {
+		shares = 2
+	}
+	if shares > 262144 {
+		shares = 262144
+	}
+	return 1 + ((shares - 2) * 9999) / 262142
+}
@@ -0,0 +1,102 @@
+package cgroups
+
+import (
+	"lumper/cgroups/subsystems"
+	"reflect"
+	"testing"
+)
+
+func TestMemorySwapMax(t *testing.T) {
+	tests := []struct {
+		memory, memorySwap string
+		want               string
+		wantErr            bool
+	}{
+		{memory: "104857600", memorySwap: "-1", want: "max"},
+		{memory: "", memorySwap: "-1", want: "max"},
+		{memory: "104857600", memorySwap: "209715200", want: "104857600"},
+		// 与内存限制相同表示不允许使用 swap
+		{memory: "104857600", memorySwap: "104857600", want: "0"},
+		{memory: "209715200", memorySwap: "104857600", wantErr: true},
+		{memory: "", memorySwap: "104857600", wantErr: true},
+		{memory: "100m", memorySwap: "104857600", wantErr: true},
+		{memory: "104857600", memorySwap: "200m", wantErr: true},
+		{memory: "104857600", memorySwap: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := memorySwapMax(tt.memory, tt.memorySwap)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("memorySwapMax(%q, %q) error = %v, wantErr %v", tt.memory, tt.memorySwap, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("memorySwapMax(%q, %q) = %q, want %q", tt.memory, tt.memorySwap, got, tt.want)
+		}
+	}
+}
+
+func TestCpuMax(t *testing.T) {
+	tests := []struct {
+		quota, period string
+		want          string
+	}{
+		{quota: "", period: "", want: "max 100000"},
+		{quota: "-1", period: "50000", want: "max 50000"},
+		{quota: "50000", period: "", want: "50000 100000"},
+		{quota: "20000", period: "10000", want: "20000 10000"},
+	}
+	for _, tt := range tests {
+		if got := cpuMax(tt.quota, tt.period); got != tt.want {
+			t.Errorf("cpuMax(%q, %q) = %q, want %q", tt.quota, tt.period, got, tt.want)
+		}
+	}
+}
+
+func TestCpuSharesToWeight(t *testing.T) {
+	tests := map[uint64]uint64{
+		0:       1,
+		2:       1,
+		1024:    39,
+		262144:  10000,
+		1000000: 10000,
+	}
+	for shares, want := range tests {
+		if got := cpuSharesToWeight(shares); got != want {
+			t.Errorf("cpuSharesToWeight(%d) = %d, want %d", shares, got, want)
+		}
+	}
+}
+
+func TestBlkioWeightToIOWeight(t *testing.T) {
+	tests := map[uint64]uint64{
+		1:    1,
+		10:   1,
+		500:  4950,
+		1000: 10000,
+		5000: 10000,
+	}
+	for weight, want := range tests {
+		if got := blkioWeightToIOWeight(weight); got != want {
+			t.Errorf("blkioWeightToIOWeight(%d) = %d, want %d", weight, got, want)
+		}
+	}
+}
+
+func TestIoMaxLines(t *testing.T) {
+	res := &subsystems.ResourceConfig{
+		DeviceReadBps:   []string{"8:0 1048576", "8:16 2097152"},
+		DeviceWriteBps:  []string{"8:0 524288"},
+		DeviceReadIOps:  []string{"8:16 100", "bad"},
+		DeviceWriteIOps: []string{"8:0 50"},
+	}
+	want := []string{
+		"8:0 rbps=1048576 wbps=524288 wiops=50",
+		"8:16 rbps=2097152 riops=100",
+	}
+	if got := ioMaxLines(res); !reflect.DeepEqual(got, want) {
+		t.Errorf("ioMaxLines() = %q, want %q", got, want)
+	}
+	if got := ioMaxLines(&subsystems.ResourceConfig{}); got != nil {
+		t.Errorf("ioMaxLines() of empty config = %q, want nil", got)
+	}
+}
@@ -0,0 +1,232 @@
+package cgroups
+
+import (
+	"encoding/binary"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// cgroup v2 没有 devices 控制器，设备白名单通过挂载 BPF_CGROUP_DEVICE 程序实现
+// 程序的参数为 struct bpf_cgroup_dev_ctx { u32 access_type; u32 major; u32 minor; }
+// access_type 的低 16 位为设备类型，高 16 位为访问类型
+const (
+	bpfDevcgDevBlock = 1
+	bpfDevcgDevChar  = 2
+
+	bpfDevcgAccMknod = 1
+	bpfDevcgAccRead  = 2
+	bpfDevcgAccWrite = 4
+)
+
+// 用到的 eBPF 指令
+const (
+	bpfLdxMemW  = 0x61 // dst = *(u32 *)(src + off)
+	bpfAlu64And = 0x57 // dst &= imm
+	bpfAlu64Rsh = 0x77 // dst >>= imm
+	bpfMov64Imm = 0xb7 // dst = imm
+	bpfMov64Reg = 0xbf // dst = src
+	bpfJneImm   = 0x55 // if dst != imm goto pc + off
+	bpfExit     = 0x95
+)
+
+type bpfInsn struct {
+	Code uint8
+	Dst  uint8
+	Src  uint8
+	Off  int16
+	Imm  int32
+}
+
+// 序列化成内核 struct bpf_insn 的格式，寄存器编号中 dst 在低 4 位
+func (insn bpfInsn) bytes() []byte {
+	buf := make([]byte, 8)
+	buf[0] = insn.Code
+	buf[1] = insn.Src << 4 | insn.Dst & 0x0f
+	binary.LittleEndian.PutUint16(buf[2:], uint16(insn.Off))
+	binary.LittleEndian.PutUint32(buf[4:], uint32(insn.Imm))
+	return buf
+}
+
+// cgroup 设备规则，-1 表示匹配所有
+type deviceRule struct {
+	Type   int32
+	Major  int64
+	Minor  int64
+	Access int32
+}
+
+// 解析 "c 1:3 rwm" 格式的设备规则，类型 a 表示所有设备
+func parseDeviceRule(rule string) (*deviceRule, error) {
+	fields := strings.Fields(rule)
+	if len(fields) == 1 && fields[0] == "a" {
+		return &deviceRule{Type: -1, Major: -1, Minor: -1, Access: bpfDevcgAccMknod | bpfDevcgAccRead | bpfDevcgAccWrite}, nil
+	}
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("invalid device rule %s", rule)
+	}
+	r := &deviceRule{}
+	switch fields[0] {
+	case "a":
+		r.Type = -1
+	case "b":
+		r.Type = bpfDevcgDevBlock
+	case "c":
+		r.Type = bpfDevcgDevChar
+	default:
+		return nil, fmt.Errorf("invalid device type in rule %s", rule)
+	}
+	numbers := strings.SplitN(fields[1], ":", 2)
+	if len(numbers) != 2 {
+		return nil, fmt.Errorf("invalid device number in rule %s", rule)
+	}
+	var err error
+	if r.Major, err = parseDeviceNumber(numbers[0]); err != nil {
+		return nil, fmt.Errorf("invalid device major in rule %s", rule)
+	}
+	if r.Minor, err = parseDeviceNumber(numbers[1]); err != nil {
+		return nil, fmt.Errorf("invalid device minor in rule %s", rule)
+	}
+	for _, c := range fields[2] {
+		switch c {
+		case 'r':
+			r.Access |= bpfDevcgAccRead
+		case 'w':
+			r.Access |= bpfDevcgAccWrite
+		case 'm':
+			r.Access |= bpfDevcgAccMknod
+		default:
+			return nil, fmt.Errorf("invalid device access in rule %s", rule)
+		}
+	}
+	return r, nil
+}
+
+func parseDeviceNumber(value string) (int64, error) {
+	if value == "*" {
+		return -1, nil
+	}
+	return strconv.ParseInt(value, 10, 32)
+}
+
+// 根据白名单生成设备过滤程序，匹配任意一条规则时返回 1 允许访问，否则返回 0
+func deviceFilterProgram(rules []string) ([]bpfInsn, error) {
+	// r2 = 设备类型，r3 = 访问类型，r4 = 主设备号，r5 = 次设备号
+	insns := []bpfInsn{
+		{Code: bpfLdxMemW, Dst: 2, Src: 1, Off: 0},
+		{Code: bpfAlu64And, Dst: 2, Imm: 0xffff},
+		{Code: bpfLdxMemW, Dst: 3, Src: 1, Off: 0},
+		{Code: bpfAlu64Rsh, Dst: 3, Imm: 16},
+		{Code: bpfLdxMemW, Dst: 4, Src: 1, Off: 4},
+		{Code: bpfLdxMemW, Dst: 5, Src: 1, Off: 8},
+	}
+	for _, rule := range rules {
+		r, err := parseDeviceRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		// 条件不满足时跳过这条规则，跳转的偏移在规则生成完之后填写
+		var block []bpfInsn
+		var jumps []int
+		addCheck := func(check ...bpfInsn) {
+			block = append(block, check...)
+			jumps = append(jumps, len(block) - 1)
+		}
+		if r.Type != -1 {
+			addCheck(bpfInsn{Code: bpfJneImm, Dst: 2, Imm: r.Type})
+		}
+		// 请求的访问类型中有规则不允许的部分
+		if denied := ^r.Access & (bpfDevcgAccMknod | bpfDevcgAccRead | bpfDevcgAccWrite); denied != 0 {
+			addCheck(
+				bpfInsn{Code: bpfMov64Reg, Dst: 1, Src: 3},
+				bpfInsn{Code: bpfAlu64And, Dst: 1, Imm: denied},
+				bpfInsn{Code: bpfJneImm, Dst: 1, Imm: 0},
+			)
+		}
+		if r.Major != -1 {
+			addCheck(bpfInsn{Code: bpfJneImm, Dst: 4, Imm: int32(r.Major)})
+		}
+		if r.Minor != -1 {
+			addCheck(bpfInsn{Code: bpfJneImm, Dst: 5, Imm: int32(r.Minor)})
+		}
+		block = append(block, bpfInsn{Code: bpfMov64Imm, Dst: 0, Imm: 1}, bpfInsn{Code: bpfExit})
+		for _, i := range jumps {
+			block[i].Off = int16(len(block) - i - 1)
+		}
+		insns = append(insns, block...)
+	}
+	return append(insns, bpfInsn{Code: bpfMov64Imm, Dst: 0, Imm: 0}, bpfInsn{Code: bpfExit}), nil
+}
+
+// 内核 union bpf_attr 中 BPF_PROG_LOAD 使用的部分
+type bpfProgLoadAttr struct {
+	ProgType    uint32
+	InsnCnt     uint32
+	Insns       uint64
+	License     uint64
+	LogLevel    uint32
+	LogSize     uint32
+	LogBuf      uint64
+	KernVersion uint32
+	ProgFlags   uint32
+}
+
+// 内核 union bpf_attr 中 BPF_PROG_ATTACH 使用的部分
+type bpfProgAttachAttr struct {
+	TargetFd     uint32
+	AttachBpfFd  uint32
+	AttachType   uint32
+	AttachFlags  uint32
+	ReplaceBpfFd uint32
+}
+
+// 加载设备过滤程序并挂载到 Cgroup 目录，已经挂载的程序会被替换
+func attachDeviceFilter(cgroupPath string, rules []string) error {
+	insns, err := deviceFilterProgram(rules)
+	if err != nil {
+		return err
+	}
+	var code []byte
+	for _, insn := range insns {
+		code = append(code, insn.bytes()...)
+	}
+	license := []byte("Apache\x00")
+	logBuf := make([]byte, 65536)
+	loadAttr := bpfProgLoadAttr{
+		ProgType: unix.BPF_PROG_TYPE_CGROUP_DEVICE,
+		InsnCnt:  uint32(len(insns)),
+		Insns:    uint64(uintptr(unsafe.Pointer(&code[0]))),
+		License:  uint64(uintptr(unsafe.Pointer(&license[0]))),
+		LogLevel: 1,
+		LogSize:  uint32(len(logBuf)),
+		LogBuf:   uint64(uintptr(unsafe.Pointer(&logBuf[0]))),
+	}
+	progFd, _, errno := unix.Syscall(unix.SYS_BPF, unix.BPF_PROG_LOAD, uintptr(unsafe.Pointer(&loadAttr)), unsafe.Sizeof(loadAttr))
+	// attr 中以整数保存的指针不会让 GC 保留这些内存
+	runtime.KeepAlive(code)
+	runtime.KeepAlive(license)
+	if errno != 0 {
+		return fmt.Errorf("load device filter error %v: %s", errno, strings.TrimRight(string(logBuf), "\x00"))
+	}
+	// 挂载之后 Cgroup 持有程序的引用，可以关闭 fd
+	defer unix.Close(int(progFd))
+
+	dir, err := os.Open(cgroupPath)
+	if err != nil {
+		return fmt.Errorf("open cgroup %s error %v", cgroupPath, err)
+	}
+	defer dir.Close()
+	attachAttr := bpfProgAttachAttr{
+		TargetFd:    uint32(dir.Fd()),
+		AttachBpfFd: uint32(progFd),
+		AttachType:  unix.BPF_CGROUP_DEVICE,
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_BPF, unix.BPF_PROG_ATTACH, uintptr(unsafe.Pointer(&attachAttr)), unsafe.Sizeof(attachAttr)); errno != 0 {
+		return fmt.Errorf("attach device filter to %s error %v", cgroupPath, errno)
+	}
+	return nil
+}
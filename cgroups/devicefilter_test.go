@@ -0,0 +1,136 @@
+package cgroups
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDeviceRule(t *testing.T) {
+	all := int32(bpfDevcgAccMknod | bpfDevcgAccRead | bpfDevcgAccWrite)
+	tests := []struct {
+		rule    string
+		want    *deviceRule
+		wantErr bool
+	}{
+		{rule: "a", want: &deviceRule{Type: -1, Major: -1, Minor: -1, Access: all}},
+		{rule: "c 1:3 rwm", want: &deviceRule{Type: bpfDevcgDevChar, Major: 1, Minor: 3, Access: all}},
+		{rule: "b 8:* r", want: &deviceRule{Type: bpfDevcgDevBlock, Major: 8, Minor: -1, Access: bpfDevcgAccRead}},
+		{rule: "c *:* m", want: &deviceRule{Type: bpfDevcgDevChar, Major: -1, Minor: -1, Access: bpfDevcgAccMknod}},
+		{rule: "a *:* rw", want: &deviceRule{Type: -1, Major: -1, Minor: -1, Access: bpfDevcgAccRead | bpfDevcgAccWrite}},
+		{rule: "x 1:3 rwm", wantErr: true},
+		{rule: "c 1 rwm", wantErr: true},
+		{rule: "c a:3 rwm", wantErr: true},
+		{rule: "c 1:b rwm", wantErr: true},
+		{rule: "c 1:3 rwx", wantErr: true},
+		{rule: "c 1:3", wantErr: true},
+		{rule: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseDeviceRule(tt.rule)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseDeviceRule(%q) error = %v, wantErr %v", tt.rule, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseDeviceRule(%q) = %+v, want %+v", tt.rule, got, tt.want)
+		}
+	}
+}
+
+// 解释执行设备过滤程序中用到的指令，返回 r0
+func runDeviceFilter(t *testing.T, insns []bpfInsn, devType, access, major, minor uint32) uint64 {
+	ctx := []uint32{access << 16 | devType, major, minor}
+	var regs [11]uint64
+	for pc := 0; pc < len(insns); pc++ {
+		insn := insns[pc]
+		switch insn.Code {
+		case bpfLdxMemW:
+			regs[insn.Dst] = uint64(ctx[insn.Off / 4])
+		case bpfAlu64And:
+			regs[insn.Dst] &= uint64(int64(insn.Imm))
+		case bpfAlu64Rsh:
+			regs[insn.Dst] >>= uint64(insn.Imm)
+		case bpfMov64Imm:
+			regs[insn.Dst] = uint64(int64(insn.Imm))
+		case bpfMov64Reg:
+			regs[insn.Dst] = regs[insn.Src]
+		case bpfJneImm:
+			if regs[insn.Dst] != uint64(int64(insn.Imm)) {
+				pc += int(insn.Off)
+			}
+		case bpfExit:
+			return regs[0]
+		default:
+			t.Fatalf("unexpected instruction %#x", insn.Code)
+		}
+	}
+	t.Fatalf("program does not exit")
+	return 0
+}
+
+func TestDeviceFilterProgram(t *testing.T) {
+	rules := []string{
+		"c *:* m",
+		"b *:* m",
+		"c 1:3 rwm",
+		"c 1:9 r",
+		"c 136:* rwm",
+		"b 8:0 rw",
+	}
+	insns, err := deviceFilterProgram(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const (
+		m = bpfDevcgAccMknod
+		r = bpfDevcgAccRead
+		w = bpfDevcgAccWrite
+		c = bpfDevcgDevChar
+		b = bpfDevcgDevBlock
+	)
+	tests := []struct {
+		name                         string
+		devType, access, major, minor uint32
+		want                         uint64
+	}{
+		{name: "mknod any char", devType: c, access: m, major: 10, minor: 200, want: 1},
+		{name: "mknod any block", devType: b, access: m, major: 8, minor: 1, want: 1},
+		{name: "read null", devType: c, access: r, major: 1, minor: 3, want: 1},
+		{name: "read write null", devType: c, access: r | w, major: 1, minor: 3, want: 1},
+		{name: "read urandom", devType: c, access: r, major: 1, minor: 9, want: 1},
+		{name: "write urandom", devType: c, access: w, major: 1, minor: 9, want: 0},
+		{name: "read write urandom", devType: c, access: r | w, major: 1, minor: 9, want: 0},
+		{name: "read zero", devType: c, access: r, major: 1, minor: 5, want: 0},
+		{name: "pty", devType: c, access: r | w, major: 136, minor: 7, want: 1},
+		{name: "read sda", devType: b, access: r, major: 8, minor: 0, want: 1},
+		{name: "read sda1", devType: b, access: r, major: 8, minor: 1, want: 0},
+		{name: "read char 8:0", devType: c, access: r, major: 8, minor: 0, want: 0},
+		{name: "read block 1:3", devType: b, access: r, major: 1, minor: 3, want: 0},
+	}
+	for _, tt := range tests {
+		if got := runDeviceFilter(t, insns, tt.devType, tt.access, tt.major, tt.minor); got != tt.want {
+			t.Errorf("%s: filter returned %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	// 空白名单拒绝所有访问
+	insns, err = deviceFilterProgram(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := runDeviceFilter(t, insns, c, r, 1, 3); got != 0 {
+		t.Errorf("empty allowlist returned %d, want 0", got)
+	}
+	// a 允许所有访问
+	insns, err = deviceFilterProgram([]string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := runDeviceFilter(t, insns, b, r | w | m, 8, 0); got != 1 {
+		t.Errorf("allow all returned %d, want 1", got)
+	}
+
+	if _, err := deviceFilterProgram([]string{"bad rule"}); err == nil {
+		t.Errorf("deviceFilterProgram() with invalid rule should fail")
+	}
+}
@@ -0,0 +1,54 @@
+package cgroups
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestCgroupManagerV2Freeze(t *testing.T) {
+	root, err := ioutil.TempDir("", "lumper-cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	manager := &CgroupManagerV2{Root: root, Path: "lumper/test"}
+	if err := os.MkdirAll(manager.fullPath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// 没有 cgroup.events 时无法确认冻结状态
+	if err := manager.Freeze(); err == nil {
+		t.Errorf("Freeze() without cgroup.events should fail")
+	}
+
+	// 用普通文件模拟内核更新后的 cgroup.events
+	setEvents := func(content string) {
+		if err := ioutil.WriteFile(path.Join(manager.fullPath(), "cgroup.events"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	readFreeze := func() string {
+		content, err := ioutil.ReadFile(path.Join(manager.fullPath(), "cgroup.freeze"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(content)
+	}
+
+	setEvents("populated 1\nfrozen 1\n")
+	if err := manager.Freeze(); err != nil {
+		t.Fatalf("Freeze() error %v", err)
+	}
+	if got := readFreeze(); got != "1" {
+		t.Errorf("cgroup.freeze = %q, want 1", got)
+	}
+
+	setEvents("populated 1\nfrozen 0\n")
+	if err := manager.Thaw(); err != nil {
+		t.Fatalf("Thaw() error %v", err)
+	}
+	if got := readFreeze(); got != "0" {
+		t.Errorf("cgroup.freeze = %q, want 0", got)
+	}
+}
@@ -0,0 +1,134 @@
+package cgroups
+
+import (
+	"fmt"
+	"io/ioutil"
+	"lumper/cgroups/subsystems"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// 从 Cgroup 统计文件中读取的资源使用情况
+type Stats struct {
+	CpuUsage    uint64 `json:"cpuUsage"`    // 累计 CPU 时间，单位为纳秒
+	MemoryUsage uint64 `json:"memoryUsage"` // 内存使用量，不包括可回收的文件缓存
+	MemoryPeak  uint64 `json:"memoryPeak"`  // 内存使用峰值
+	MemoryLimit uint64 `json:"memoryLimit"` // 内存限制，0 表示不限制
+	PidsCurrent uint64 `json:"pidsCurrent"` // 进程数
+	PidsLimit   uint64 `json:"pidsLimit"`   // 进程数限制，0 表示不限制
+	BlkioRead   uint64 `json:"blkioRead"`   // 块设备读取字节数
+	BlkioWrite  uint64 `json:"blkioWrite"`  // 块设备写入字节数
+}
+
+// 读取各个 Subsystem 中的统计文件，没有挂载的 Subsystem 不统计
+func (c *CgroupManagerV1) GetStats() (*Stats, error) {
+	paths := c.Paths()
+	exists := false
+	for _, subsysCgroupPath := range paths {
+		if _, err := os.Stat(subsysCgroupPath); err == nil {
+			exists = true
+			break
+		}
+	}
+	if !exists {
+		return nil, fmt.Errorf("cgroup %s not found", c.Path)
+	}
+	stats := &Stats{}
+	if cpuacct, ok := paths["cpuacct"]; ok {
+		stats.CpuUsage, _ = readUintFile(path.Join(cpuacct, "cpuacct.usage"))
+	}
+	if memory, ok := paths["memory"]; ok {
+		usage, _ := readUintFile(path.Join(memory, "memory.usage_in_bytes"))
+		inactiveFile, _ := subsystems.ReadKeyedValue(path.Join(memory, "memory.stat"), "total_inactive_file")
+		stats.MemoryUsage = subtract(usage, inactiveFile)
+		stats.MemoryPeak, _ = readUintFile(path.Join(memory, "memory.max_usage_in_bytes"))
+		stats.MemoryLimit, _ = readUintFile(path.Join(memory, "memory.limit_in_bytes"))
+		// 不限制时 limit_in_bytes 为一个接近 int64 最大值的数
+		if stats.MemoryLimit >= 1 << 62 {
+			stats.MemoryLimit = 0
+		}
+	}
+	if pids, ok := paths["pids"]; ok {
+		stats.PidsCurrent, _ = readUintFile(path.Join(pids, "pids.current"))
+		stats.PidsLimit, _ = readUintFile(path.Join(pids, "pids.max"))
+	}
+	if blkio, ok := paths["blkio"]; ok {
+		// 每行格式为 "<major>:<minor> <Read|Write|...> <字节数>"
+		if content, err := ioutil.ReadFile(path.Join(blkio, "blkio.throttle.io_service_bytes")); err == nil {
+			for _, line := range strings.Split(string(content), "\n") {
+				fields := strings.Fields(line)
+				if len(fields) != 3 {
+					continue
+				}
+				value, _ := strconv.ParseUint(fields[2], 10, 64)
+				switch fields[1] {
+				case "Read":
+					stats.BlkioRead += value
+				case "Write":
+					stats.BlkioWrite += value
+				}
+			}
+		}
+	}
+	return stats, nil
+}
+
+// 读取 cgroup v2 中各个控制器的统计文件
+func (c *CgroupManagerV2) GetStats() (*Stats, error) {
+	cgroupPath := c.fullPath()
+	if _, err := os.Stat(cgroupPath); err != nil {
+		return nil, fmt.Errorf("cgroup %s not found", c.Path)
+	}
+	stats := &Stats{}
+	if usage, err := subsystems.ReadKeyedValue(path.Join(cgroupPath, "cpu.stat"), "usage_usec"); err == nil {
+		stats.CpuUsage = usage * 1000
+	}
+	usage, _ := readUintFile(path.Join(cgroupPath, "memory.current"))
+	inactiveFile, _ := subsystems.ReadKeyedValue(path.Join(cgroupPath, "memory.stat"), "inactive_file")
+	stats.MemoryUsage = subtract(usage, inactiveFile)
+	stats.MemoryPeak, _ = readUintFile(path.Join(cgroupPath, "memory.peak"))
+	stats.MemoryLimit, _ = readUintFile(path.Join(cgroupPath, "memory.max"))
+	stats.PidsCurrent, _ = readUintFile(path.Join(cgroupPath, "pids.current"))
+	stats.PidsLimit, _ = readUintFile(path.Join(cgroupPath, "pids.max"))
+	// 每行格式为 "<major>:<minor> rbytes=N wbytes=N rios=N wios=N ..."
+	if content, err := ioutil.ReadFile(path.Join(cgroupPath, "io.stat")); err == nil {
+		for _, line := range strings.Split(string(content), "\n") {
+			for _, field := range strings.Fields(line) {
+				kv := strings.SplitN(field, "=", 2)
+				if len(kv) != 2 {
+					continue
+				}
+				value, _ := strconv.ParseUint(kv[1], 10, 64)
+				switch kv[0] {
+				case "rbytes":
+					stats.BlkioRead += value
+				case "wbytes":
+					stats.BlkioWrite += value
+				}
+			}
+		}
+	}
+	return stats, nil
+}
+
+// 读取只包含一个数字的 Cgroup 文件，max 表示不限制，返回 0
+func readUintFile(file string) (uint64, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	value := strings.TrimSpace(string(content))
+	if value == "max" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+func subtract(a, b uint64) uint64 {
+	if a < b {
+		return 0
+	}
+	return a - b
+}
@@ -0,0 +1,110 @@
+package subsystems
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Blkio Subsystem 的实现
+type BlkioSubSystem struct {
+}
+
+func (s *BlkioSubSystem) Name() string {
+	return "blkio"
+}
+
+func (s *BlkioSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
+	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, true); err == nil {
+		if res.BlkioWeight != "" {
+			// 使用 CFQ 调度器时为 blkio.weight，使用 BFQ 调度器时为 blkio.bfq.weight
+			weightFile := path.Join(subsysCgroupPath, "blkio.weight")
+			if _, err := os.Stat(weightFile); os.IsNotExist(err) {
+				weightFile = path.Join(subsysCgroupPath, "blkio.bfq.weight")
+			}
+			if err := ioutil.WriteFile(weightFile, []byte(res.BlkioWeight), 0644); err != nil {
+				return fmt.Errorf("set cgroup blkio weight fail %v", err)
+			}
+		}
+		throttles := map[string][]string{
+			"blkio.throttle.read_bps_device":   res.DeviceReadBps,
+			"blkio.throttle.write_bps_device":  res.DeviceWriteBps,
+			"blkio.throttle.read_iops_device":  res.DeviceReadIOps,
+			"blkio.throttle.write_iops_device": res.DeviceWriteIOps,
+		}
+		for file, devices := range throttles {
+			// 每次只能写入一个设备的限制
+			for _, device := range devices {
+				if err := ioutil.WriteFile(path.Join(subsysCgroupPath, file), []byte(device), 0644); err != nil {
+					return fmt.Errorf("set cgroup %s fail %v", file, err)
+				}
+			}
+		}
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (s *BlkioSubSystem) Apply(cgroupPath string, pid int) error {
+	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false); err == nil {
+		if err := ioutil.WriteFile(path.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+			return fmt.Errorf("set cgroup proc fail %v", err)
+		}
+		return nil
+	} else {
+		return fmt.Errorf("get cgroup %s error %v", cgroupPath, err)
+	}
+}
+
+func (s *BlkioSubSystem) Remove(cgroupPath string) error {
+	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false); err == nil {
+		return os.RemoveAll(subsysCgroupPath)
+	} else {
+		return err
+	}
+}
+
+// 解析 <设备路径>:<速率> 形式的设备限制，转换成 cgroup 中 <major>:<minor> <速率> 的形式
+func ParseThrottleDevice(device string, bytes bool) (string, error) {
+	i := strings.LastIndex(device, ":")
+	if i <= 0 || i == len(device) - 1 {
+		return "", fmt.Errorf("bad format of device %s, expected <device-path>:<rate>", device)
+	}
+	// 字节速率支持 k、m、g 等单位，IO 次数只能为整数
+	var rate uint64
+	if bytes {
+		value, err := RAMInBytes(device[i+1:])
+		if err != nil || value <= 0 {
+			return "", fmt.Errorf("invalid rate of device %s", device)
+		}
+		rate = uint64(value)
+	} else {
+		value, err := strconv.ParseUint(device[i+1:], 10, 64)
+		if err != nil || value == 0 {
+			return "", fmt.Errorf("invalid rate of device %s", device)
+		}
+		rate = value
+	}
+	major, minor, err := GetDeviceNumber(device[:i])
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d:%d %d", major, minor, rate), nil
+}
+
+// 获取块设备的主设备号和次设备号
+func GetDeviceNumber(devicePath string) (uint32, uint32, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(devicePath, &st); err != nil {
+		return 0, 0, fmt.Errorf("stat device %s error %v", devicePath, err)
+	}
+	if st.Mode & unix.S_IFMT != unix.S_IFBLK {
+		return 0, 0, fmt.Errorf("%s is not a block device", devicePath)
+	}
+	return unix.Major(uint64(st.Rdev)), unix.Minor(uint64(st.Rdev)), nil
+}
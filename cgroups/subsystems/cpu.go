@@ -3,11 +3,33 @@ package subsystems
 import (
 	"fmt"
 	"io/ioutil"
+	"math"
 	"os"
 	"path"
 	"strconv"
 )
 
+// CFS 调度周期的默认值，单位为微秒
+const DefaultCpuPeriod = 100000
+
+// 将 CPU 个数转换成 CFS 配额和周期，如 1.5 个 CPU 为每 100000 微秒使用 150000 微秒
+func CpusToQuota(cpus string) (string, string, error) {
+	value, err := strconv.ParseFloat(cpus, 64)
+	if err != nil || value <= 0 || math.IsInf(value, 0) || math.IsNaN(value) {
+		return "", "", fmt.Errorf("invalid cpus %s", cpus)
+	}
+	if value > float64(math.MaxInt64 / DefaultCpuPeriod) {
+		return "", "", fmt.Errorf("cpus %s is too large", cpus)
+	}
+	// 四舍五入，避免 0.29 等小数乘以周期后因浮点误差少 1 微秒
+	quota := int64(math.Round(value * DefaultCpuPeriod))
+	// 内核要求配额不小于 1000 微秒
+	if quota < 1000 {
+		return "", "", fmt.Errorf("cpus %s is too small", cpus)
+	}
+	return strconv.FormatInt(quota, 10), strconv.Itoa(DefaultCpuPeriod), nil
+}
+
 // Cpu Subsystem 的实现
 type CpuSubSystem struct {
 }
@@ -23,6 +45,17 @@ func (s *CpuSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
 				return fmt.Errorf("set cgroup cpu share fail %v", err)
 			}
 		}
+		// 先设置周期再设置配额，避免配额超出旧周期的限制
+		if res.CpuPeriod != ""{
+			if err := ioutil.WriteFile(path.Join(subsysCgroupPaht, "cpu.cfs_period_us"), []byte(res.CpuPeriod), 0644); err != nil {
+				return fmt.Errorf("set cgroup cpu period fail %v", err)
+			}
+		}
+		if res.CpuQuota != ""{
+			if err := ioutil.WriteFile(path.Join(subsysCgroupPaht, "cpu.cfs_quota_us"), []byte(res.CpuQuota), 0644); err != nil {
+				return fmt.Errorf("set cgroup cpu quota fail %v", err)
+			}
+		}
 		return nil
 	} else {
 		return err
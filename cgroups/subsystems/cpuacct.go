@@ -0,0 +1,42 @@
+package subsystems
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+)
+
+// Cpuacct Subsystem 的实现
+type CpuacctSubSystem struct {
+}
+
+func (s *CpuacctSubSystem) Name() string {
+	return "cpuacct"
+}
+
+// cpuacct 只统计 CPU 使用量，没有资源限制，只创建 Cgroup
+func (s *CpuacctSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
+	_, err := GetCgroupPath(s.Name(), cgroupPath, true)
+	return err
+}
+
+func (s *CpuacctSubSystem) Apply(cgroupPath string, pid int) error {
+	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, true); err == nil {
+		if err := ioutil.WriteFile(path.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+			return fmt.Errorf("set cgroup proc fail %v", err)
+		}
+		return nil
+	} else {
+		return fmt.Errorf("get cgroup %s error %v", cgroupPath, err)
+	}
+}
+
+func (s *CpuacctSubSystem) Remove(cgroupPath string) error {
+	// cpuacct 通常与 cpu 挂载在同一个 hierarchy 中，此时 Cgroup 已随 cpu 一起删除
+	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false); err == nil {
+		return os.RemoveAll(subsysCgroupPath)
+	}
+	return nil
+}
@@ -5,7 +5,17 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"sort"
 	"strconv"
+	"strings"
+)
+
+const (
+	// 宿主机在线的 CPU 和 NUMA 节点
+	onlineCpus = "/sys/devices/system/cpu/online"
+	onlineMems = "/sys/devices/system/node/online"
+	// CPU 和 NUMA 节点编号的上限，避免 0-1000000000 这样的范围展开时耗尽内存
+	maxCpusetID = 1 << 16
 )
 
 // Cpu Set Subsystem 的实现
@@ -17,28 +27,33 @@ func (s *CpuSetSubSystem) Name() string {
 }
 
 func (s *CpuSetSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
-	if subsysCgroupPaht, err := GetCgroupPath(s.Name(), cgroupPath, true); err == nil {
-		if res.CpuSet != ""{
-			if err := ioutil.WriteFile(path.Join(subsysCgroupPaht, "cpuset.cpus"), []byte(res.CpuSet), 0644); err != nil {
-				return fmt.Errorf("set cgroup cpuset fail %v", err)
-			}
-		}
-		return nil
-	} else {
+	subsysCgroupPath, err := s.ensureCgroup(cgroupPath)
+	if err != nil {
 		return err
 	}
-
+	// mems 需要先于 cpus 设置，否则缩小范围时可能与子 Cgroup 冲突
+	if res.CpuSetMems != "" {
+		if err := ioutil.WriteFile(path.Join(subsysCgroupPath, "cpuset.mems"), []byte(res.CpuSetMems), 0644); err != nil {
+			return fmt.Errorf("set cgroup cpuset.mems fail %v", err)
+		}
+	}
+	if res.CpuSet != "" {
+		if err := ioutil.WriteFile(path.Join(subsysCgroupPath, "cpuset.cpus"), []byte(res.CpuSet), 0644); err != nil {
+			return fmt.Errorf("set cgroup cpuset.cpus fail %v", err)
+		}
+	}
+	return nil
 }
 
 func (s *CpuSetSubSystem) Apply(cgroupPath string, pid int) error {
-	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false); err == nil {
-		if err := ioutil.WriteFile(path.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
-			return fmt.Errorf("set cgroup proc fail %v", err)
-		}
-		return nil
-	} else {
+	subsysCgroupPath, err := s.ensureCgroup(cgroupPath)
+	if err != nil {
 		return fmt.Errorf("get cgroup %s error %v", cgroupPath, err)
 	}
+	if err := ioutil.WriteFile(path.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+		return fmt.Errorf("set cgroup proc fail %v", err)
+	}
+	return nil
 }
 
 func (s *CpuSetSubSystem) Remove(cgroupPath string) error {
@@ -49,3 +64,111 @@ func (s *CpuSetSubSystem) Remove(cgroupPath string) error {
 	}
 }
 
+// 逐级创建 Cgroup，新建的 Cgroup 中 cpus 和 mems 为空，无法加入进程，需要从父 Cgroup 继承
+func (s *CpuSetSubSystem) ensureCgroup(cgroupPath string) (string, error) {
+	current := FindCgroupMountPoint(s.Name())
+	if current == "" {
+		return "", fmt.Errorf("cgroup subsystem %s is not mounted", s.Name())
+	}
+	for _, dir := range strings.Split(strings.Trim(path.Clean(cgroupPath), "/"), "/") {
+		parent := current
+		current = path.Join(current, dir)
+		if err := os.Mkdir(current, 0755); err != nil && !os.IsExist(err) {
+			return "", fmt.Errorf("error create cgroup %v", err)
+		}
+		for _, file := range []string{"cpuset.cpus", "cpuset.mems"} {
+			if err := inheritCpuset(parent, current, file); err != nil {
+				return "", err
+			}
+		}
+	}
+	return current, nil
+}
+
+// 如果 Cgroup 中的 file 为空，则使用父 Cgroup 中的值
+func inheritCpuset(parent, current, file string) error {
+	content, err := ioutil.ReadFile(path.Join(current, file))
+	if err != nil {
+		return fmt.Errorf("read %s error %v", file, err)
+	}
+	if strings.TrimSpace(string(content)) != "" {
+		return nil
+	}
+	if content, err = ioutil.ReadFile(path.Join(parent, file)); err != nil {
+		return fmt.Errorf("read %s error %v", file, err)
+	}
+	if err := ioutil.WriteFile(path.Join(current, file), content, 0644); err != nil {
+		return fmt.Errorf("set cgroup %s fail %v", file, err)
+	}
+	return nil
+}
+
+// 检查 CPU 和 NUMA 节点列表是否都在宿主机上在线
+func ValidateCpuset(cpus, mems string) error {
+	if cpus != "" {
+		if err := validateCpusetList("cpuset-cpus", cpus, onlineCpus); err != nil {
+			return err
+		}
+	}
+	if mems != "" {
+		if err := validateCpusetList("cpuset-mems", mems, onlineMems); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateCpusetList(name, list, onlineFile string) error {
+	requested, err := ParseCpusetList(list)
+	if err != nil {
+		return fmt.Errorf("invalid %s %s: %v", name, list, err)
+	}
+	// 没有 NUMA 的宿主机上可能不存在 node 目录，此时只有节点 0
+	online := map[int]bool{0: true}
+	if content, err := ioutil.ReadFile(onlineFile); err == nil {
+		ids, err := ParseCpusetList(strings.TrimSpace(string(content)))
+		if err != nil {
+			return fmt.Errorf("parse %s error %v", onlineFile, err)
+		}
+		online = map[int]bool{}
+		for _, id := range ids {
+			online[id] = true
+		}
+	}
+	for _, id := range requested {
+		if !online[id] {
+			return fmt.Errorf("invalid %s %s: %d is not online", name, list, id)
+		}
+	}
+	return nil
+}
+
+// 解析 "0-3,5" 格式的列表
+func ParseCpusetList(list string) ([]int, error) {
+	set := map[int]bool{}
+	for _, item := range strings.Split(list, ",") {
+		bounds := strings.SplitN(item, "-", 2)
+		start, err := strconv.Atoi(bounds[0])
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("invalid item %q", item)
+		}
+		end := start
+		if len(bounds) == 2 {
+			if end, err = strconv.Atoi(bounds[1]); err != nil || end < start {
+				return nil, fmt.Errorf("invalid range %q", item)
+			}
+		}
+		if end >= maxCpusetID {
+			return nil, fmt.Errorf("%d is out of range", end)
+		}
+		for id := start; id <= end; id++ {
+			set[id] = true
+		}
+	}
+	var ids []int
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
@@ -0,0 +1,83 @@
+package subsystems
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseCpusetList(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    []int
+		wantErr bool
+	}{
+		{list: "0", want: []int{0}},
+		{list: "0-3", want: []int{0, 1, 2, 3}},
+		{list: "0-1,3", want: []int{0, 1, 3}},
+		{list: "5,1-2,2", want: []int{1, 2, 5}},
+		{list: "2-2", want: []int{2}},
+		{list: "65535", want: []int{65535}},
+		{list: "", wantErr: true},
+		{list: "a", wantErr: true},
+		{list: "-1", wantErr: true},
+		{list: "1-", wantErr: true},
+		{list: "3-1", wantErr: true},
+		{list: "0,,1", wantErr: true},
+		{list: "0-1-2", wantErr: true},
+		{list: " 1", wantErr: true},
+		{list: "65536", wantErr: true},
+		{list: "0-1000000000", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseCpusetList(tt.list)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseCpusetList(%q) error = %v, wantErr %v", tt.list, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseCpusetList(%q) = %v, want %v", tt.list, got, tt.want)
+		}
+	}
+}
+
+func TestValidateCpusetList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lumper-cpuset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	online := filepath.Join(dir, "online")
+	if err := ioutil.WriteFile(online, []byte("0-3,6\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing")
+	broken := filepath.Join(dir, "broken")
+	if err := ioutil.WriteFile(broken, []byte("x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		list       string
+		onlineFile string
+		wantErr    bool
+	}{
+		{list: "0-3", onlineFile: online},
+		{list: "1,6", onlineFile: online},
+		{list: "4", onlineFile: online, wantErr: true},
+		{list: "3-6", onlineFile: online, wantErr: true},
+		{list: "bad", onlineFile: online, wantErr: true},
+		// 没有 online 文件时只有 0 在线
+		{list: "0", onlineFile: missing},
+		{list: "1", onlineFile: missing, wantErr: true},
+		{list: "0", onlineFile: broken, wantErr: true},
+	}
+	for _, tt := range tests {
+		err := validateCpusetList("cpuset-cpus", tt.list, tt.onlineFile)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateCpusetList(%q, %s) error = %v, wantErr %v", tt.list, filepath.Base(tt.onlineFile), err, tt.wantErr)
+		}
+	}
+}
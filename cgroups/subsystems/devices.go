@@ -0,0 +1,57 @@
+package subsystems
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+)
+
+// Devices Subsystem 的实现
+type DevicesSubSystem struct {
+}
+
+func (s *DevicesSubSystem) Name() string {
+	return "devices"
+}
+
+// 先禁止访问所有设备，再按白名单逐条放开
+func (s *DevicesSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
+	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, true); err == nil {
+		// 旧版本的容器没有设备白名单，不做限制
+		if len(res.Devices) == 0 {
+			return nil
+		}
+		if err := ioutil.WriteFile(path.Join(subsysCgroupPath, "devices.deny"), []byte("a"), 0644); err != nil {
+			return fmt.Errorf("set cgroup devices.deny fail %v", err)
+		}
+		for _, rule := range res.Devices {
+			if err := ioutil.WriteFile(path.Join(subsysCgroupPath, "devices.allow"), []byte(rule), 0644); err != nil {
+				return fmt.Errorf("set cgroup devices.allow %s fail %v", rule, err)
+			}
+		}
+		return nil
+	} else {
+		return err
+	}
+}
+
+func (s *DevicesSubSystem) Apply(cgroupPath string, pid int) error {
+	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, true); err == nil {
+		if err := ioutil.WriteFile(path.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+			return fmt.Errorf("set cgroup proc fail %v", err)
+		}
+		return nil
+	} else {
+		return fmt.Errorf("get cgroup %s error %v", cgroupPath, err)
+	}
+}
+
+func (s *DevicesSubSystem) Remove(cgroupPath string) error {
+	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false); err == nil {
+		return os.RemoveAll(subsysCgroupPath)
+	} else {
+		return err
+	}
+}
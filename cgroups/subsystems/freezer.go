@@ -0,0 +1,77 @@
+package subsystems
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FreezerFrozen = "FROZEN"
+	FreezerThawed = "THAWED"
+	// 等待 Cgroup 中所有进程冻结的时间
+	freezeTimeout = 10 * time.Second
+)
+
+// Freezer Subsystem 的实现
+type FreezerSubSystem struct {
+}
+
+func (s *FreezerSubSystem) Name() string {
+	return "freezer"
+}
+
+// freezer 没有资源限制，只创建 Cgroup
+func (s *FreezerSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
+	_, err := GetCgroupPath(s.Name(), cgroupPath, true)
+	return err
+}
+
+func (s *FreezerSubSystem) Apply(cgroupPath string, pid int) error {
+	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, true); err == nil {
+		if err := ioutil.WriteFile(path.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+			return fmt.Errorf("set cgroup proc fail %v", err)
+		}
+		return nil
+	} else {
+		return fmt.Errorf("get cgroup %s error %v", cgroupPath, err)
+	}
+}
+
+func (s *FreezerSubSystem) Remove(cgroupPath string) error {
+	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false); err == nil {
+		return os.RemoveAll(subsysCgroupPath)
+	} else {
+		return err
+	}
+}
+
+// 冻结或解冻 Cgroup 中的所有进程，冻结时等待状态从 FREEZING 变为 FROZEN
+func (s *FreezerSubSystem) Freeze(cgroupPath string, state string) error {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	stateFile := path.Join(subsysCgroupPath, "freezer.state")
+	deadline := time.Now().Add(freezeTimeout)
+	for {
+		if err := ioutil.WriteFile(stateFile, []byte(state), 0644); err != nil {
+			return fmt.Errorf("set cgroup freezer state fail %v", err)
+		}
+		current, err := ioutil.ReadFile(stateFile)
+		if err != nil {
+			return fmt.Errorf("read cgroup freezer state fail %v", err)
+		}
+		if strings.TrimSpace(string(current)) == state {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timeout waiting for cgroup freezer state %s", state)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path"
 	"strconv"
+	"strings"
 )
 
 // Memory Subsystem 的实现
@@ -23,6 +24,28 @@ func (s *MemorySubSystem) Set(cgroupPath string, res *ResourceConfig) error {
 				return fmt.Errorf("set cgroup memory fail %v", err)
 			}
  		}
+		// memsw 必须在 limit_in_bytes 之后设置，且不能小于内存限制
+		files := []struct{
+			name  string
+			value string
+		}{
+			{"memory.memsw.limit_in_bytes", res.MemorySwap},
+			{"memory.soft_limit_in_bytes", res.MemoryReservation},
+			{"memory.kmem.limit_in_bytes", res.KernelMemory},
+		}
+		for _, file := range files {
+			if file.value == "" {
+				continue
+			}
+			if err := ioutil.WriteFile(path.Join(subsysCgroupPaht, file.name), []byte(file.value), 0644); err != nil {
+				return fmt.Errorf("set cgroup %s fail %v", file.name, err)
+			}
+		}
+		if res.OomKillDisable {
+			if err := ioutil.WriteFile(path.Join(subsysCgroupPaht, "memory.oom_control"), []byte("1"), 0644); err != nil {
+				return fmt.Errorf("set cgroup memory.oom_control fail %v", err)
+			}
+		}
  		return nil
 	} else {
 		return err
@@ -49,3 +72,27 @@ func (s *MemorySubSystem) Remove(cgroupPath string) error {
 	}
 }
 
+
+// 读取 Cgroup 中因内存不足被杀死的进程数
+func (s *MemorySubSystem) OOMKillCount(cgroupPath string) (uint64, error) {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return 0, err
+	}
+	return ReadKeyedValue(path.Join(subsysCgroupPath, "memory.oom_control"), "oom_kill")
+}
+
+// 读取 "key value" 格式的 Cgroup 文件中指定 key 的值
+func ReadKeyedValue(file, key string) (uint64, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == key {
+			return strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+	return 0, fmt.Errorf("%s not found in %s", key, file)
+}
@@ -0,0 +1,57 @@
+package subsystems
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+)
+
+// Pids Subsystem 的实现
+type PidsSubSystem struct {
+}
+
+func (s *PidsSubSystem) Name() string {
+	return "pids"
+}
+
+func (s *PidsSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
+	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, true); err == nil {
+		if res.PidsLimit != "" {
+			if err := ioutil.WriteFile(path.Join(subsysCgroupPath, "pids.max"), []byte(PidsMax(res.PidsLimit)), 0644); err != nil {
+				return fmt.Errorf("set cgroup pids limit fail %v", err)
+			}
+		}
+		return nil
+	} else {
+		return err
+	}
+}
+
+// pids.max 中不限制写作 max，命令行中使用 -1 或 0 表示
+func PidsMax(limit string) string {
+	if limit == "-1" || limit == "0" {
+		return "max"
+	}
+	return limit
+}
+
+func (s *PidsSubSystem) Apply(cgroupPath string, pid int) error {
+	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false); err == nil {
+		if err := ioutil.WriteFile(path.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+			return fmt.Errorf("set cgroup proc fail %v", err)
+		}
+		return nil
+	} else {
+		return fmt.Errorf("get cgroup %s error %v", cgroupPath, err)
+	}
+}
+
+func (s *PidsSubSystem) Remove(cgroupPath string) error {
+	if subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false); err == nil {
+		return os.RemoveAll(subsysCgroupPath)
+	} else {
+		return err
+	}
+}
@@ -3,7 +3,51 @@ package subsystems
 type ResourceConfig struct {
 	CpuShare string
 	CpuSet string
+	// 允许使用的 NUMA 节点
+	CpuSetMems string
 	MemoryLimit string
+	// 内存加 swap 的总限制，-1 表示不限制 swap
+	MemorySwap string
+	// 内存软限制
+	MemoryReservation string
+	KernelMemory string
+	// 内存不足时不杀死容器内进程
+	OomKillDisable bool
+	// 容器 init 进程的 oom_score_adj，不写入 Cgroup
+	OomScoreAdj string
+	CpuQuota string
+	CpuPeriod string
+	PidsLimit string
+	BlkioWeight string
+	// 设备限制，格式为 <major>:<minor> <速率>
+	DeviceReadBps []string
+	DeviceWriteBps []string
+	DeviceReadIOps []string
+	DeviceWriteIOps []string
+	// 设备访问白名单，格式为 <type> <major>:<minor> <permissions>
+	Devices []string
+}
+
+// 是否设置了需要由指定 Subsystem 实现的资源限制
+func (r *ResourceConfig) Requests(subsystem string) bool {
+	if r == nil {
+		return false
+	}
+	switch subsystem {
+	case "cpu":
+		return r.CpuShare != "" || r.CpuQuota != "" || r.CpuPeriod != ""
+	case "cpuset":
+		return r.CpuSet != "" || r.CpuSetMems != ""
+	case "memory":
+		return r.MemoryLimit != "" || r.MemorySwap != "" || r.MemoryReservation != "" || r.KernelMemory != "" || r.OomKillDisable
+	case "pids":
+		return r.PidsLimit != ""
+	case "blkio":
+		return r.BlkioWeight != "" || len(r.DeviceReadBps) > 0 || len(r.DeviceWriteBps) > 0 || len(r.DeviceReadIOps) > 0 || len(r.DeviceWriteIOps) > 0
+	case "devices":
+		return len(r.Devices) > 0
+	}
+	return false
 }
 
 type Subsystem interface {
@@ -20,7 +64,12 @@ type Subsystem interface {
 var (
 	SubsystemsIns = []Subsystem{
 		&CpuSubSystem{},
+		&CpuacctSubSystem{},
 		&CpuSetSubSystem{},
 		&MemorySubSystem{},
+		&PidsSubSystem{},
+		&BlkioSubSystem{},
+		&FreezerSubSystem{},
+		&DevicesSubSystem{},
 	}
 )
\ No newline at end of file
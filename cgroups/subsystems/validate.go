@@ -0,0 +1,162 @@
+package subsystems
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// 内存限制过小时容器 init 进程无法启动
+	minMemoryLimit = 6 * 1024 * 1024
+	minCpuQuota    = 1000
+	minCpuPeriod   = 1000
+	maxCpuPeriod   = 1000000
+	minCpuShare    = 2
+	maxCpuShare    = 262144
+	minBlkioWeight = 10
+	maxBlkioWeight = 1000
+)
+
+var (
+	sizeRegexp = regexp.MustCompile(`^(\d+(?:\.\d+)?)([kKmMgGtT]?)[bB]?$`)
+	sizeUnits  = map[string]float64{
+		"":  1,
+		"k": 1 << 10,
+		"m": 1 << 20,
+		"g": 1 << 30,
+		"t": 1 << 40,
+	}
+)
+
+// 将 "100m"、"1.5g"、"512kb" 等格式的大小转换成字节数，单位按 1024 进制计算
+func RAMInBytes(size string) (int64, error) {
+	matches := sizeRegexp.FindStringSubmatch(strings.TrimSpace(size))
+	if matches == nil {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	value, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	// 超出 int64 范围时转换结果不确定
+	bytes := value * sizeUnits[strings.ToLower(matches[2])]
+	if bytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("size %q is too large", size)
+	}
+	return int64(bytes), nil
+}
+
+// 检查资源配置，并将大小统一转换成字节数，使写入 Cgroup 的值与内核无关
+func (r *ResourceConfig) Validate() error {
+	var memoryLimit int64
+	if r.MemoryLimit != "" {
+		limit, err := parseSize("memory", r.MemoryLimit, minMemoryLimit)
+		if err != nil {
+			return err
+		}
+		memoryLimit = limit
+		r.MemoryLimit = strconv.FormatInt(limit, 10)
+	}
+	// swap 限制的是内存加 swap 的总量，需要同时指定内存限制
+	if r.MemorySwap != "" {
+		if r.MemoryLimit == "" {
+			return fmt.Errorf("memory-swap requires memory to be set")
+		}
+		if r.MemorySwap != "-1" {
+			swap, err := parseSize("memory-swap", r.MemorySwap, 1)
+			if err != nil {
+				return err
+			}
+			if swap < memoryLimit {
+				return fmt.Errorf("memory-swap %s should be larger than memory %s", r.MemorySwap, r.MemoryLimit)
+			}
+			r.MemorySwap = strconv.FormatInt(swap, 10)
+		}
+	}
+	if r.MemoryReservation != "" {
+		reservation, err := parseSize("memory-reservation", r.MemoryReservation, 1)
+		if err != nil {
+			return err
+		}
+		if memoryLimit > 0 && reservation > memoryLimit {
+			return fmt.Errorf("memory-reservation %s should be smaller than memory %s", r.MemoryReservation, r.MemoryLimit)
+		}
+		r.MemoryReservation = strconv.FormatInt(reservation, 10)
+	}
+	if r.KernelMemory != "" {
+		kernelMemory, err := parseSize("kernel-memory", r.KernelMemory, minMemoryLimit)
+		if err != nil {
+			return err
+		}
+		r.KernelMemory = strconv.FormatInt(kernelMemory, 10)
+	}
+	if r.CpuShare != "" {
+		if err := checkRange("cpushare", r.CpuShare, minCpuShare, maxCpuShare); err != nil {
+			return err
+		}
+	}
+	if r.CpuQuota != "" && r.CpuQuota != "-1" {
+		if err := checkRange("cpu-quota", r.CpuQuota, minCpuQuota, -1); err != nil {
+			return err
+		}
+	}
+	if r.CpuPeriod != "" {
+		if err := checkRange("cpu-period", r.CpuPeriod, minCpuPeriod, maxCpuPeriod); err != nil {
+			return err
+		}
+	}
+	if err := ValidateCpuset(r.CpuSet, r.CpuSetMems); err != nil {
+		return err
+	}
+	// -1 和 0 表示不限制
+	if r.PidsLimit != "" {
+		if err := checkRange("pids-limit", r.PidsLimit, -1, -1); err != nil {
+			return err
+		}
+	}
+	// 0 表示不设置权重
+	if r.BlkioWeight == "0" {
+		r.BlkioWeight = ""
+	}
+	if r.BlkioWeight != "" {
+		if err := checkRange("blkio-weight", r.BlkioWeight, minBlkioWeight, maxBlkioWeight); err != nil {
+			return err
+		}
+	}
+	if r.OomScoreAdj != "" {
+		if err := checkRange("oom-score-adj", r.OomScoreAdj, -1000, 1000); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 解析大小并检查下限
+func parseSize(name, size string, min int64) (int64, error) {
+	value, err := RAMInBytes(size)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %s, expected a number with optional unit b, k, m, g or t", name, size)
+	}
+	if value < min {
+		return 0, fmt.Errorf("invalid %s %s, minimum is %d bytes", name, size, min)
+	}
+	return value, nil
+}
+
+// 检查整数是否在 [min, max] 范围内，max 为 -1 时不限制上限
+func checkRange(name, value string, min, max int64) error {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s %s, expected an integer", name, value)
+	}
+	if n < min || (max != -1 && n > max) {
+		if max == -1 {
+			return fmt.Errorf("invalid %s %s, minimum is %d", name, value, min)
+		}
+		return fmt.Errorf("invalid %s %s, should be in range [%d, %d]", name, value, min, max)
+	}
+	return nil
+}
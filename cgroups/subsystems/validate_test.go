@@ -0,0 +1,268 @@
+package subsystems
+
+import (
+	"golang.org/x/sys/unix"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestRAMInBytes(t *testing.T) {
+	tests := []struct {
+		size    string
+		want    int64
+		wantErr bool
+	}{
+		{size: "0", want: 0},
+		{size: "1024", want: 1024},
+		{size: "1024b", want: 1024},
+		{size: "1k", want: 1 << 10},
+		{size: "1K", want: 1 << 10},
+		{size: "512kb", want: 512 << 10},
+		{size: "100m", want: 100 << 20},
+		{size: "100MB", want: 100 << 20},
+		{size: "1.5g", want: 3 << 29},
+		{size: "2t", want: 2 << 40},
+		{size: " 64m ", want: 64 << 20},
+		{size: "1.5", want: 1},
+		{size: "", wantErr: true},
+		{size: "m", wantErr: true},
+		{size: "-1", wantErr: true},
+		{size: "-1m", wantErr: true},
+		{size: "1x", wantErr: true},
+		{size: "1mm", wantErr: true},
+		{size: "1 m", wantErr: true},
+		{size: "1.m", wantErr: true},
+		{size: "1e3", wantErr: true},
+		{size: "8388608t", wantErr: true},
+		{size: "99999999999999999999", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := RAMInBytes(tt.size)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("RAMInBytes(%q) error = %v, wantErr %v", tt.size, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("RAMInBytes(%q) = %d, want %d", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestResourceConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		res     ResourceConfig
+		want    ResourceConfig
+		wantErr bool
+	}{
+		{name: "empty"},
+		{
+			name: "sizes are converted to bytes",
+			res:  ResourceConfig{MemoryLimit: "100m", MemorySwap: "200m", MemoryReservation: "50m", KernelMemory: "64m"},
+			want: ResourceConfig{MemoryLimit: "104857600", MemorySwap: "209715200", MemoryReservation: "52428800", KernelMemory: "67108864"},
+		},
+		{
+			name: "unlimited swap",
+			res:  ResourceConfig{MemoryLimit: "100m", MemorySwap: "-1"},
+			want: ResourceConfig{MemoryLimit: "104857600", MemorySwap: "-1"},
+		},
+		{
+			name: "swap equal to memory",
+			res:  ResourceConfig{MemoryLimit: "100m", MemorySwap: "100m"},
+			want: ResourceConfig{MemoryLimit: "104857600", MemorySwap: "104857600"},
+		},
+		{
+			name: "reservation without limit",
+			res:  ResourceConfig{MemoryReservation: "1g"},
+			want: ResourceConfig{MemoryReservation: "1073741824"},
+		},
+		{name: "memory below minimum", res: ResourceConfig{MemoryLimit: "1m"}, wantErr: true},
+		{name: "invalid memory", res: ResourceConfig{MemoryLimit: "lots"}, wantErr: true},
+		{name: "swap without memory", res: ResourceConfig{MemorySwap: "1g"}, wantErr: true},
+		{name: "swap below memory", res: ResourceConfig{MemoryLimit: "100m", MemorySwap: "50m"}, wantErr: true},
+		{name: "reservation above memory", res: ResourceConfig{MemoryLimit: "100m", MemoryReservation: "200m"}, wantErr: true},
+		{name: "kernel memory below minimum", res: ResourceConfig{KernelMemory: "1k"}, wantErr: true},
+		{
+			name: "cpu values in range",
+			res:  ResourceConfig{CpuShare: "512", CpuQuota: "50000", CpuPeriod: "100000"},
+			want: ResourceConfig{CpuShare: "512", CpuQuota: "50000", CpuPeriod: "100000"},
+		},
+		{
+			name: "unlimited cpu quota",
+			res:  ResourceConfig{CpuQuota: "-1"},
+			want: ResourceConfig{CpuQuota: "-1"},
+		},
+		{name: "cpu share too small", res: ResourceConfig{CpuShare: "1"}, wantErr: true},
+		{name: "cpu share too large", res: ResourceConfig{CpuShare: "262145"}, wantErr: true},
+		{name: "cpu share not integer", res: ResourceConfig{CpuShare: "1.5"}, wantErr: true},
+		{name: "cpu quota too small", res: ResourceConfig{CpuQuota: "999"}, wantErr: true},
+		{name: "cpu period too large", res: ResourceConfig{CpuPeriod: "1000001"}, wantErr: true},
+		{
+			name: "cpu 0 is always online",
+			res:  ResourceConfig{CpuSet: "0", CpuSetMems: "0"},
+			want: ResourceConfig{CpuSet: "0", CpuSetMems: "0"},
+		},
+		{name: "invalid cpuset", res: ResourceConfig{CpuSet: "0-"}, wantErr: true},
+		{
+			name: "pids limit",
+			res:  ResourceConfig{PidsLimit: "-1"},
+			want: ResourceConfig{PidsLimit: "-1"},
+		},
+		{name: "pids limit below -1", res: ResourceConfig{PidsLimit: "-2"}, wantErr: true},
+		{
+			name: "zero blkio weight is unset",
+			res:  ResourceConfig{BlkioWeight: "0"},
+			want: ResourceConfig{},
+		},
+		{
+			name: "blkio weight",
+			res:  ResourceConfig{BlkioWeight: "500"},
+			want: ResourceConfig{BlkioWeight: "500"},
+		},
+		{name: "blkio weight too small", res: ResourceConfig{BlkioWeight: "9"}, wantErr: true},
+		{name: "blkio weight too large", res: ResourceConfig{BlkioWeight: "1001"}, wantErr: true},
+		{
+			name: "oom score adj",
+			res:  ResourceConfig{OomScoreAdj: "-1000"},
+			want: ResourceConfig{OomScoreAdj: "-1000"},
+		},
+		{name: "oom score adj too large", res: ResourceConfig{OomScoreAdj: "1001"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		res := tt.res
+		err := res.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(res, tt.want) {
+			t.Errorf("%s: Validate() = %+v, want %+v", tt.name, res, tt.want)
+		}
+	}
+}
+
+func TestRequests(t *testing.T) {
+	var nilConfig *ResourceConfig
+	if nilConfig.Requests("memory") {
+		t.Errorf("nil config should not request any subsystem")
+	}
+	res := &ResourceConfig{PidsLimit: "10", DeviceReadBps: []string{"8:0 1048576"}}
+	for subsystem, want := range map[string]bool{
+		"cpu":     false,
+		"cpuacct": false,
+		"cpuset":  false,
+		"memory":  false,
+		"pids":    true,
+		"blkio":   true,
+		"freezer": false,
+		"devices": false,
+	} {
+		if got := res.Requests(subsystem); got != want {
+			t.Errorf("Requests(%q) = %v, want %v", subsystem, got, want)
+		}
+	}
+	if !(&ResourceConfig{OomKillDisable: true}).Requests("memory") {
+		t.Errorf("oom kill disable should request memory subsystem")
+	}
+}
+
+func TestCpusToQuota(t *testing.T) {
+	tests := []struct {
+		cpus    string
+		quota   string
+		wantErr bool
+	}{
+		{cpus: "1", quota: "100000"},
+		{cpus: "1.5", quota: "150000"},
+		{cpus: "0.5", quota: "50000"},
+		{cpus: "0.01", quota: "1000"},
+		{cpus: "0.29", quota: "29000"},
+		{cpus: "0.57", quota: "57000"},
+		{cpus: "2.01", quota: "201000"},
+		{cpus: "0.000015", wantErr: true},
+		{cpus: "0.009", wantErr: true},
+		{cpus: "0", wantErr: true},
+		{cpus: "-1", wantErr: true},
+		{cpus: "", wantErr: true},
+		{cpus: "one", wantErr: true},
+		{cpus: "1c", wantErr: true},
+		{cpus: "NaN", wantErr: true},
+		{cpus: "Inf", wantErr: true},
+		{cpus: "1e300", wantErr: true},
+	}
+	for _, tt := range tests {
+		quota, period, err := CpusToQuota(tt.cpus)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("CpusToQuota(%q) error = %v, wantErr %v", tt.cpus, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (quota != tt.quota || period != "100000") {
+			t.Errorf("CpusToQuota(%q) = %s, %s, want %s, 100000", tt.cpus, quota, period, tt.quota)
+		}
+	}
+}
+
+func TestParseThrottleDevice(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lumper-blkio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// 创建块设备文件，设备号不需要对应真实设备
+	blockDevice := path.Join(dir, "sda")
+	if err := unix.Mknod(blockDevice, unix.S_IFBLK | 0600, int(unix.Mkdev(8, 16))); err != nil {
+		t.Skipf("mknod block device error %v", err)
+	}
+	charDevice := path.Join(dir, "null")
+	if err := unix.Mknod(charDevice, unix.S_IFCHR | 0600, int(unix.Mkdev(1, 3))); err != nil {
+		t.Skipf("mknod char device error %v", err)
+	}
+	// 路径中包含 : 时以最后一个 : 分隔速率
+	colonDevice := path.Join(dir, "disk:0")
+	if err := unix.Mknod(colonDevice, unix.S_IFBLK | 0600, int(unix.Mkdev(259, 1))); err != nil {
+		t.Skipf("mknod block device error %v", err)
+	}
+	tests := []struct {
+		device  string
+		bytes   bool
+		want    string
+		wantErr bool
+	}{
+		{device: blockDevice + ":1048576", bytes: true, want: "8:16 1048576"},
+		{device: blockDevice + ":1mb", bytes: true, want: "8:16 1048576"},
+		{device: blockDevice + ":10M", bytes: true, want: "8:16 10485760"},
+		{device: blockDevice + ":1.5k", bytes: true, want: "8:16 1536"},
+		{device: blockDevice + ":100", bytes: false, want: "8:16 100"},
+		{device: colonDevice + ":20", bytes: false, want: "259:1 20"},
+		// 速率错误
+		{device: blockDevice + ":0", bytes: true, wantErr: true},
+		{device: blockDevice + ":0", bytes: false, wantErr: true},
+		{device: blockDevice + ":-1", bytes: true, wantErr: true},
+		{device: blockDevice + ":-1", bytes: false, wantErr: true},
+		{device: blockDevice + ":1k", bytes: false, wantErr: true},
+		{device: blockDevice + ":1.5", bytes: false, wantErr: true},
+		{device: blockDevice + ":fast", bytes: true, wantErr: true},
+		// 格式错误
+		{device: blockDevice, bytes: true, wantErr: true},
+		{device: blockDevice + ":", bytes: true, wantErr: true},
+		{device: ":1mb", bytes: true, wantErr: true},
+		{device: "", bytes: true, wantErr: true},
+		// 设备错误
+		{device: charDevice + ":1mb", bytes: true, wantErr: true},
+		{device: dir + ":1mb", bytes: true, wantErr: true},
+		{device: path.Join(dir, "missing") + ":1mb", bytes: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseThrottleDevice(tt.device, tt.bytes)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseThrottleDevice(%q, %v) error = %v, wantErr %v", tt.device, tt.bytes, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseThrottleDevice(%q, %v) = %q, want %q", tt.device, tt.bytes, got, tt.want)
+		}
+	}
+}
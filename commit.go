@@ -5,6 +5,7 @@ import (
 	"github.com/urfave/cli"
 	"lumper/container"
 	"os/exec"
+	"strings"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -29,5 +30,21 @@ func commitContainer(containerName, imageName string)  {
 	// 打包容器 rootfs
 	if _, err := exec.Command("tar", "-czf", imageTar, "-C", mntUrl, ".").CombinedOutput(); err != nil {
 		log.Errorf("tar folder %s error %v", mntUrl, err)
+		return
+	}
+	// 保存容器的环境变量作为镜像的环境变量，主机名和终端类型由新容器决定
+	containerInfo, err := loadContainerInfo(containerName)
+	if err != nil {
+		log.Errorf("get container %s info error %v", containerName, err)
+		return
+	}
+	var imageEnv []string
+	for _, kv := range container.ContainerEnv(containerInfo) {
+		if !strings.HasPrefix(kv, "HOSTNAME=") && !strings.HasPrefix(kv, "TERM=") {
+			imageEnv = append(imageEnv, kv)
+		}
+	}
+	if err := container.WriteImageEnv(imageName, imageEnv); err != nil {
+		log.Errorf("write image env error %v", err)
 	}
 }
@@ -4,22 +4,33 @@ import (
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/sys/unix"
+	"lumper/cgroups/subsystems"
 	"os"
 	"os/exec"
+	"time"
 )
 
 var (
+	CREATED 			string = "created"
 	RUNNING 			string = "running"
+	RESTARTING 			string = "restarting"
+	PAUSED 				string = "paused"
 	STOP 				string = "stopped"
 	EXIT 				string = "exited"
 	DefaultInfoLocation string = "/var/lib/lumper/containers/%s/"
 	ConfigName 			string = "config.json"
 	ContainerLogFile 	string = "container.log"
+	ShimLogFile 		string = "shim.log"
 	Overlay2Location	string = "/var/lib/lumper/overlay2/%s/"
 	ImageLocation		string = "/var/lib/lumper/overlay2/images/%s/"
+	VolumeLocation		string = "/var/lib/lumper/volumes/%s/"
 	RootUrl 			string = "/root/"
 	MntUrl 				string = "/root/mnt/%s/"
 	WriteLayerUrl 		string = "/root/writeLayer/%s/"
+	CreatedTimeLayout	string = "2006/1/2 15:04:05"
+	DefaultCgroupParent string = "lumper"
+	// 旧版本所有容器共用的 Cgroup
+	LegacyCgroupPath	string = "lumper-cgroup"
 )
 
 type ContainerInfo struct {
@@ -32,15 +43,47 @@ type ContainerInfo struct {
 	Volume      string `json:"volume"` // 容器数据卷
 	Network		string `json:"network"` // 网络驱动名
 	IPAddress	string `json:"ipaddress"` // IP地址
+	NetworkConnected bool `json:"networkConnected"` // 是否占用网络端点、端口映射和 IP
 	PortMapping []string `json:"portmapping"` // 端口映射
+	Image       string   `json:"image"` // 镜像名
+	Args        []string `json:"args"` // 容器内 init 运行命令及参数
+	Env         []string `json:"env"` // 环境变量
+	Resource    *subsystems.ResourceConfig `json:"resource"` // 资源限制
+	CgroupPath  string   `json:"cgroupPath"` // Cgroup 在 hierarchy 中的路径
+	PidStartTime uint64   `json:"pidStartTime"` // init 进程的启动时间，用于判断 PID 是否被复用
+	ShimPid     string    `json:"shimPid"` // 监控进程在宿主机上的 PID
+	ExitCode    int       `json:"exitCode"` // 退出码
+	StartedAt   time.Time `json:"startedAt"` // 启动时间
+	FinishedAt  time.Time `json:"finishedAt"` // 退出时间
+	RestartPolicy   RestartPolicy `json:"restartPolicy"` // 重启策略
+	RestartCount    int  `json:"restartCount"` // 重启次数
+	ManuallyStopped bool `json:"manuallyStopped"` // 是否被手动停止
+	Labels      map[string]string `json:"labels"` // 标签
+	OOMKilled   bool      `json:"oomKilled"` // 是否因内存不足被杀死
+	OOMKillCount uint64   `json:"oomKillCount"` // 启动时 Cgroup 中的 OOM kill 计数
+	Devices     []Device  `json:"devices"` // 透传的宿主机设备
+	ProcessConfig // init 进程的主机名、用户、工作目录和资源限制
 }
 
-// 创建一个父进程
-func NewParentProcess(tty bool, containerName , volume , imageName string, env []string) (*exec.Cmd, *os.File) {
+// 获取容器的 Cgroup 路径，旧版本创建的容器没有记录时使用共用的 Cgroup
+func (c *ContainerInfo) GetCgroupPath() string {
+	if c.CgroupPath == "" {
+		return LegacyCgroupPath
+	}
+	return c.CgroupPath
+}
+
+// 创建一个父进程，返回发送启动配置的管道写入端和接收 init 错误的管道读取端
+func NewParentProcess(tty bool, containerName , volume , imageName string) (*exec.Cmd, *os.File, *os.File) {
 	readPipe, writePipe, err := NewPipe()
 	if err != nil {
 		log.Errorf("new pipe error %v", err)
-		return nil, nil
+		return nil, nil, nil
+	}
+	errorReadPipe, errorWritePipe, err := NewPipe()
+	if err != nil {
+		log.Errorf("new pipe error %v", err)
+		return nil, nil, nil
 	}
 	// 克隆一个新进程，使用 namespace 隔离新进程和外部环境
 	cmd := exec.Command("/proc/self/exe", "init")
@@ -56,22 +99,22 @@ func NewParentProcess(tty bool, containerName , volume , imageName string, env [
 		dirUrl := fmt.Sprintf(DefaultInfoLocation, containerName)
 		if err := os.MkdirAll(dirUrl, 0622); err != nil {
 			log.Errorf("mkdir %s error %v", dirUrl, err)
-			return nil, nil
+			return nil, nil, nil
 		}
 		stdLogFilePath := dirUrl + ContainerLogFile
-		stdLogFile, err := os.Create(stdLogFilePath)
+		// 以追加方式打开，重新启动容器时保留之前的日志
+		stdLogFile, err := os.OpenFile(stdLogFilePath, os.O_CREATE | os.O_WRONLY | os.O_APPEND, 0644)
 		if err != nil {
 			log.Errorf("create file %s error %v", stdLogFilePath, err)
-			return nil, nil
+			return nil, nil, nil
 		}
 		cmd.Stdout = stdLogFile
 	}
-	// 传入管道文件读取端的句柄
-	cmd.ExtraFiles = []*os.File{readPipe}
-	cmd.Env = append(os.Environ(), env...)
+	// 传入启动配置管道的读取端和错误管道的写入端，分别为 fd 3 和 fd 4
+	cmd.ExtraFiles = []*os.File{readPipe, errorWritePipe}
 	NewWorkSpace(volume, containerName, imageName)
 	cmd.Dir = fmt.Sprintf(Overlay2Location, containerName) + "merged"
-	return cmd, writePipe
+	return cmd, writePipe, errorReadPipe
 }
 
 // 创建一个管道
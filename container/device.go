@@ -0,0 +1,142 @@
+package container
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 容器内的设备节点
+type Device struct {
+	Type        string      `json:"type"` // 设备类型，c 为字符设备，b 为块设备
+	Path        string      `json:"path"` // 容器内的设备路径
+	Major       int64       `json:"major"` // 主设备号
+	Minor       int64       `json:"minor"` // 次设备号
+	Permissions string      `json:"permissions"` // Cgroup 权限，r 读、w 写、m 创建设备节点
+	FileMode    os.FileMode `json:"fileMode"` // 设备文件权限
+	Uid         uint32      `json:"uid"`
+	Gid         uint32      `json:"gid"`
+}
+
+var (
+	// 容器 init 时在 /dev 下创建的标准设备
+	DefaultDevices = []Device{
+		{Type: "c", Path: "/dev/null", Major: 1, Minor: 3, Permissions: "rwm", FileMode: 0666},
+		{Type: "c", Path: "/dev/zero", Major: 1, Minor: 5, Permissions: "rwm", FileMode: 0666},
+		{Type: "c", Path: "/dev/full", Major: 1, Minor: 7, Permissions: "rwm", FileMode: 0666},
+		{Type: "c", Path: "/dev/random", Major: 1, Minor: 8, Permissions: "rwm", FileMode: 0666},
+		{Type: "c", Path: "/dev/urandom", Major: 1, Minor: 9, Permissions: "rwm", FileMode: 0666},
+		{Type: "c", Path: "/dev/tty", Major: 5, Minor: 0, Permissions: "rwm", FileMode: 0666},
+	}
+	// 默认允许的设备访问规则，允许创建任意设备节点，但只能读写标准设备
+	defaultDeviceRules = []string{
+		"c *:* m",
+		"b *:* m",
+		// /dev/ptmx 和 devpts 中的伪终端
+		"c 5:2 rwm",
+		"c 136:* rwm",
+	}
+)
+
+// Cgroup devices.allow 中的规则，格式为 "<type> <major>:<minor> <permissions>"
+func (d *Device) CgroupRule() string {
+	return fmt.Sprintf("%s %d:%d %s", d.Type, d.Major, d.Minor, d.Permissions)
+}
+
+// 生成容器的设备访问白名单，包括标准设备和透传的宿主机设备
+func DeviceRules(devices []Device) []string {
+	rules := append([]string{}, defaultDeviceRules...)
+	for _, device := range DefaultDevices {
+		rules = append(rules, device.CgroupRule())
+	}
+	for _, device := range devices {
+		rules = append(rules, device.CgroupRule())
+	}
+	return rules
+}
+
+// 解析 --device 参数，格式为 <宿主机路径>[:<容器内路径>][:<权限>]
+func ParseDevice(spec string) (*Device, error) {
+	parts := strings.Split(spec, ":")
+	hostPath, containerPath, permissions := parts[0], parts[0], "rwm"
+	switch len(parts) {
+	case 1:
+	case 2:
+		// 第二部分不是路径时作为权限
+		if isDevicePermissions(parts[1]) {
+			permissions = parts[1]
+		} else {
+			containerPath = parts[1]
+		}
+	case 3:
+		containerPath, permissions = parts[1], parts[2]
+	default:
+		return nil, fmt.Errorf("invalid device specification %s", spec)
+	}
+	if !isDevicePermissions(permissions) {
+		return nil, fmt.Errorf("invalid device permissions %s in %s", permissions, spec)
+	}
+	if !filepath.IsAbs(containerPath) {
+		return nil, fmt.Errorf("device path %s in container must be absolute", containerPath)
+	}
+	var st unix.Stat_t
+	if err := unix.Stat(hostPath, &st); err != nil {
+		return nil, fmt.Errorf("stat device %s error %v", hostPath, err)
+	}
+	var deviceType string
+	switch st.Mode & unix.S_IFMT {
+	case unix.S_IFCHR:
+		deviceType = "c"
+	case unix.S_IFBLK:
+		deviceType = "b"
+	default:
+		return nil, fmt.Errorf("%s is not a device", hostPath)
+	}
+	return &Device{
+		Type:        deviceType,
+		Path:        filepath.Clean(containerPath),
+		Major:       int64(unix.Major(uint64(st.Rdev))),
+		Minor:       int64(unix.Minor(uint64(st.Rdev))),
+		Permissions: permissions,
+		FileMode:    os.FileMode(st.Mode & 0777),
+		Uid:         st.Uid,
+		Gid:         st.Gid,
+	}, nil
+}
+
+// 权限只能由 r、w、m 组成
+func isDevicePermissions(permissions string) bool {
+	if permissions == "" {
+		return false
+	}
+	for _, c := range permissions {
+		if !strings.ContainsRune("rwm", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// 在容器的 /dev 中创建设备节点
+func createDeviceNode(device Device) error {
+	if err := os.MkdirAll(filepath.Dir(device.Path), 0755); err != nil {
+		return err
+	}
+	mode := uint32(device.FileMode)
+	if device.Type == "b" {
+		mode |= unix.S_IFBLK
+	} else {
+		mode |= unix.S_IFCHR
+	}
+	dev := int(unix.Mkdev(uint32(device.Major), uint32(device.Minor)))
+	if err := unix.Mknod(device.Path, mode, dev); err != nil && !os.IsExist(err) {
+		return fmt.Errorf("mknod %s error %v", device.Path, err)
+	}
+	// mknod 受 umask 影响，重新设置权限
+	if err := os.Chmod(device.Path, device.FileMode); err != nil {
+		return err
+	}
+	return os.Chown(device.Path, int(device.Uid), int(device.Gid))
+}
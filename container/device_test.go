@@ -0,0 +1,84 @@
+package container
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParseDevice(t *testing.T) {
+	file, err := ioutil.TempFile("", "lumper-device")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	tests := []struct {
+		spec    string
+		want    Device
+		wantErr bool
+	}{
+		{spec: "/dev/null", want: Device{Type: "c", Path: "/dev/null", Major: 1, Minor: 3, Permissions: "rwm"}},
+		{spec: "/dev/null:r", want: Device{Type: "c", Path: "/dev/null", Major: 1, Minor: 3, Permissions: "r"}},
+		{spec: "/dev/null:/dev/mynull", want: Device{Type: "c", Path: "/dev/mynull", Major: 1, Minor: 3, Permissions: "rwm"}},
+		{spec: "/dev/zero:/dev/sub/../myzero:rw", want: Device{Type: "c", Path: "/dev/myzero", Major: 1, Minor: 5, Permissions: "rw"}},
+		{spec: "/dev/null:relative", wantErr: true},
+		{spec: "/dev/null:", wantErr: true},
+		{spec: "/dev/null:/dev/null:", wantErr: true},
+		{spec: "/dev/null:/dev/null:rwx", wantErr: true},
+		{spec: "/dev/null:/a:r:extra", wantErr: true},
+		{spec: "/dev/lumper-not-exist", wantErr: true},
+		{spec: file.Name(), wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDevice(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseDevice(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		// 文件权限和属主取决于宿主机，只比较设备信息
+		got.FileMode, got.Uid, got.Gid = 0, 0, 0
+		if !reflect.DeepEqual(*got, tt.want) {
+			t.Errorf("ParseDevice(%q) = %+v, want %+v", tt.spec, *got, tt.want)
+		}
+	}
+}
+
+func TestDeviceRules(t *testing.T) {
+	rules := DeviceRules([]Device{{Type: "b", Major: 8, Minor: 0, Permissions: "r"}})
+	want := len(defaultDeviceRules) + len(DefaultDevices) + 1
+	if len(rules) != want {
+		t.Fatalf("DeviceRules() returned %d rules, want %d", len(rules), want)
+	}
+	if rules[0] != "c *:* m" || rules[len(rules) - 1] != "b 8:0 r" {
+		t.Errorf("DeviceRules() = %q", rules)
+	}
+	// 不能修改默认规则
+	DeviceRules(nil)[0] = "a"
+	if defaultDeviceRules[0] != "c *:* m" {
+		t.Errorf("DeviceRules() modified default rules")
+	}
+}
+
+func TestIsDevicePermissions(t *testing.T) {
+	tests := map[string]bool{
+		"r":   true,
+		"rw":  true,
+		"rwm": true,
+		"mwr": true,
+		"":    false,
+		"x":   false,
+		"rwx": false,
+		"/r":  false,
+	}
+	for permissions, want := range tests {
+		if got := isDevicePermissions(permissions); got != want {
+			t.Errorf("isDevicePermissions(%q) = %v, want %v", permissions, got, want)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package container
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// 容器默认的 PATH
+const DefaultPathEnv = "PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// 容器的默认环境变量，不继承宿主机的环境变量
+func DefaultEnv(hostname string, tty bool) []string {
+	env := []string{DefaultPathEnv, "HOSTNAME=" + hostname}
+	if tty {
+		env = append(env, "TERM=xterm")
+	}
+	return env
+}
+
+// 容器进程的环境变量，旧版本记录的环境变量只有 -e 指定的部分，补上默认值
+func ContainerEnv(containerInfo *ContainerInfo) []string {
+	if getEnv(containerInfo.Env, "PATH") == "" {
+		return MergeEnv(DefaultEnv(containerInfo.Hostname, false), containerInfo.Env)
+	}
+	return containerInfo.Env
+}
+
+// 读取镜像的环境变量，保存在镜像压缩包旁的 <镜像名>.env 中，不存在时返回空
+func ReadImageEnv(imageName string) ([]string, error) {
+	envFile := RootUrl + imageName + ".env"
+	if _, err := os.Stat(envFile); os.IsNotExist(err) {
+		return nil, nil
+	}
+	return ParseEnvFile(envFile)
+}
+
+// 以 dotenv 格式保存镜像的环境变量
+func WriteImageEnv(imageName string, env []string) error {
+	var content strings.Builder
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	for _, kv := range env {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		content.WriteString(parts[0] + "=\"" + replacer.Replace(parts[1]) + "\"\n")
+	}
+	envFile := RootUrl + imageName + ".env"
+	if err := ioutil.WriteFile(envFile, []byte(content.String()), 0644); err != nil {
+		return fmt.Errorf("write env file %s error %v", envFile, err)
+	}
+	return nil
+}
+
+// 解析 dotenv 格式的环境变量文件，支持注释、export 前缀和引号
+func ParseEnvFile(envFile string) ([]string, error) {
+	file, err := os.Open(envFile)
+	if err != nil {
+		return nil, fmt.Errorf("open env file %s error %v", envFile, err)
+	}
+	defer file.Close()
+
+	var env []string
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		kv := strings.SplitN(line, "=", 2)
+		key := strings.TrimSpace(kv[0])
+		if key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("invalid variable name %q in %s line %d", key, envFile, lineNumber)
+		}
+		// 只有变量名时使用宿主机上的值
+		if len(kv) == 1 {
+			if value, ok := os.LookupEnv(key); ok {
+				env = append(env, key + "=" + value)
+			}
+			continue
+		}
+		value, err := unquoteEnvValue(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, fmt.Errorf("%v in %s line %d", err, envFile, lineNumber)
+		}
+		env = append(env, key + "=" + value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read env file %s error %v", envFile, err)
+	}
+	return env, nil
+}
+
+// 解析变量值，单引号中的内容原样保留，双引号中支持 \n、\t、\" 和 \\ 转义
+// 引号之后以及没有引号的值中，空白之后以 # 开始的部分为注释
+func unquoteEnvValue(value string) (string, error) {
+	if value == "" || (value[0] != '\'' && value[0] != '"') {
+		return stripEnvComment(value), nil
+	}
+	quote := value[0]
+	var unquoted strings.Builder
+	for i := 1; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == quote:
+			if rest := strings.TrimSpace(value[i+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+				return "", fmt.Errorf("unexpected characters after quoted value %s", value)
+			}
+			return unquoted.String(), nil
+		case quote == '"' && c == '\\' && i + 1 < len(value):
+			i++
+			switch value[i] {
+			case 'n':
+				unquoted.WriteByte('\n')
+			case 't':
+				unquoted.WriteByte('\t')
+			case '"', '\\':
+				unquoted.WriteByte(value[i])
+			default:
+				// 不认识的转义原样保留
+				unquoted.WriteByte('\\')
+				unquoted.WriteByte(value[i])
+			}
+		default:
+			unquoted.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unterminated quoted value %s", value)
+}
+
+// 去掉没有引号的值中的行尾注释
+func stripEnvComment(value string) string {
+	for i := 1; i < len(value); i++ {
+		if value[i] == '#' && (value[i-1] == ' ' || value[i-1] == '\t') {
+			return strings.TrimSpace(value[:i])
+		}
+	}
+	return value
+}
+
+// 解析 -e 参数，只有变量名时使用宿主机上的值，宿主机上不存在则忽略
+func ParseEnv(values []string) []string {
+	var env []string
+	for _, value := range values {
+		if strings.Contains(value, "=") {
+			env = append(env, value)
+		} else if hostValue, ok := os.LookupEnv(value); ok {
+			env = append(env, value + "=" + hostValue)
+		}
+	}
+	return env
+}
+
+// 合并多组环境变量，同名变量以后面的为准，保持第一次出现的顺序
+func MergeEnv(envs ...[]string) []string {
+	var keys []string
+	values := map[string]string{}
+	for _, env := range envs {
+		for _, kv := range env {
+			key := strings.SplitN(kv, "=", 2)[0]
+			if _, ok := values[key]; !ok {
+				keys = append(keys, key)
+			}
+			values[key] = kv
+		}
+	}
+	merged := make([]string, 0, len(keys))
+	for _, key := range keys {
+		merged = append(merged, values[key])
+	}
+	return merged
+}
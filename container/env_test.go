@@ -0,0 +1,255 @@
+package container
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestUnquoteEnvValue(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: ``, want: ``},
+		{value: `plain`, want: `plain`},
+		{value: `plain # comment`, want: `plain`},
+		{value: "plain\t# comment", want: `plain`},
+		{value: `a#b`, want: `a#b`},
+		{value: `#value`, want: `#value`},
+		{value: `'single $HOME \n'`, want: `single $HOME \n`},
+		{value: `'single # not comment'`, want: `single # not comment`},
+		{value: `'single' # comment`, want: `single`},
+		{value: `"double"`, want: `double`},
+		{value: `"bar baz"`, want: `bar baz`},
+		{value: `"line\nbreak"`, want: "line\nbreak"},
+		{value: `"tab\tsep"`, want: "tab\tsep"},
+		{value: `"escaped \"quote\""`, want: `escaped "quote"`},
+		{value: `"back\\slash"`, want: `back\slash`},
+		{value: `"literal \\n"`, want: `literal \n`},
+		{value: `"trailing\\"`, want: `trailing\`},
+		{value: `"unknown \x escape"`, want: `unknown \x escape`},
+		{value: `"double" # comment`, want: `double`},
+		{value: `"a # b"`, want: `a # b`},
+		{value: `""`, want: ``},
+		{value: `''`, want: ``},
+		{value: `"unterminated`, wantErr: true},
+		{value: `'unterminated`, wantErr: true},
+		{value: `"escaped end\"`, wantErr: true},
+		{value: `"double"trailing`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := unquoteEnvValue(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("unquoteEnvValue(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("unquoteEnvValue(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseEnvFile(t *testing.T) {
+	os.Setenv("LUMPER_TEST_HOST_VAR", "from-host")
+	defer os.Unsetenv("LUMPER_TEST_HOST_VAR")
+	os.Unsetenv("LUMPER_TEST_MISSING_VAR")
+
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "comments and blank lines",
+			content: "# comment\n\n  # indented comment\nA=1\n",
+			want:    []string{"A=1"},
+		},
+		{
+			name:    "export prefix and spaces",
+			content: "export A=1\n  B = two  \nexport C=\"three four\"\n",
+			want:    []string{"A=1", "B=two", "C=three four"},
+		},
+		{
+			name:    "empty value",
+			content: "A=\nB=\"\"\n",
+			want:    []string{"A=", "B="},
+		},
+		{
+			name:    "value containing equals",
+			content: "URL=http://host/?a=1&b=2\n",
+			want:    []string{"URL=http://host/?a=1&b=2"},
+		},
+		{
+			name:    "name only takes host value",
+			content: "LUMPER_TEST_HOST_VAR\nLUMPER_TEST_MISSING_VAR\n",
+			want:    []string{"LUMPER_TEST_HOST_VAR=from-host"},
+		},
+		{
+			name:    "duplicate keys are kept in order",
+			content: "A=1\nA=2\n",
+			want:    []string{"A=1", "A=2"},
+		},
+		{
+			name:    "invalid name",
+			content: "BAD NAME=1\n",
+			wantErr: true,
+		},
+		{
+			name:    "empty name",
+			content: "=1\n",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated quote",
+			content: "A=\"open\n",
+			wantErr: true,
+		},
+	}
+	dir, err := ioutil.TempDir("", "lumper-env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, tt := range tests {
+		envFile := filepath.Join(dir, "test.env")
+		if err := ioutil.WriteFile(envFile, []byte(tt.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ParseEnvFile(envFile)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: ParseEnvFile() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ParseEnvFile() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := ParseEnvFile(filepath.Join(dir, "missing.env")); err == nil {
+		t.Errorf("ParseEnvFile() of missing file should fail")
+	}
+}
+
+func TestWriteImageEnvRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lumper-image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	oldRootUrl := RootUrl
+	RootUrl = dir + "/"
+	defer func() { RootUrl = oldRootUrl }()
+
+	env := []string{
+		"PATH=/usr/bin:/bin",
+		"EMPTY=",
+		`QUOTES=say "hi"`,
+		`BACKSLASH=C:\dir\n`,
+		"MULTI=line1\nline2",
+		"TAB=a\tb",
+		"HASH=a # b",
+		"EQUALS=a=b",
+	}
+	if err := WriteImageEnv("img", env); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadImageEnv("img")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, env) {
+		t.Errorf("ReadImageEnv() = %q, want %q", got, env)
+	}
+
+	if got, err := ReadImageEnv("missing"); err != nil || got != nil {
+		t.Errorf("ReadImageEnv() of image without env = %q, %v, want nil, nil", got, err)
+	}
+}
+
+func TestMergeEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		envs [][]string
+		want []string
+	}{
+		{
+			name: "empty",
+			want: []string{},
+		},
+		{
+			name: "later overrides earlier and keeps first position",
+			envs: [][]string{
+				{"PATH=/bin", "HOSTNAME=a"},
+				{"FOO=image", "PATH=/usr/bin"},
+				{"FOO=user"},
+			},
+			want: []string{"PATH=/usr/bin", "HOSTNAME=a", "FOO=user"},
+		},
+		{
+			name: "duplicates in one list",
+			envs: [][]string{{"A=1", "B=2", "A=3"}},
+			want: []string{"A=3", "B=2"},
+		},
+		{
+			name: "empty value overrides",
+			envs: [][]string{{"A=1"}, {"A="}},
+			want: []string{"A="},
+		},
+		{
+			name: "value with equals",
+			envs: [][]string{{"A=b=c"}, {"D=e"}},
+			want: []string{"A=b=c", "D=e"},
+		},
+	}
+	for _, tt := range tests {
+		if got := MergeEnv(tt.envs...); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: MergeEnv() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseEnv(t *testing.T) {
+	os.Setenv("LUMPER_TEST_HOST_VAR", "from-host")
+	defer os.Unsetenv("LUMPER_TEST_HOST_VAR")
+	os.Unsetenv("LUMPER_TEST_MISSING_VAR")
+
+	got := ParseEnv([]string{"A=1", "B=", "LUMPER_TEST_HOST_VAR", "LUMPER_TEST_MISSING_VAR", "C=x=y"})
+	want := []string{"A=1", "B=", "LUMPER_TEST_HOST_VAR=from-host", "C=x=y"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseEnv() = %q, want %q", got, want)
+	}
+}
+
+func TestContainerEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		info ContainerInfo
+		want []string
+	}{
+		{
+			name: "recorded env is used as is",
+			info: ContainerInfo{Env: []string{"PATH=/custom", "A=1"}, ProcessConfig: ProcessConfig{Hostname: "h"}},
+			want: []string{"PATH=/custom", "A=1"},
+		},
+		{
+			name: "legacy env without PATH gets defaults",
+			info: ContainerInfo{Env: []string{"A=1"}, ProcessConfig: ProcessConfig{Hostname: "h"}},
+			want: []string{DefaultPathEnv, "HOSTNAME=h", "A=1"},
+		},
+		{
+			name: "legacy env keeps user HOSTNAME",
+			info: ContainerInfo{Env: []string{"HOSTNAME=user"}, ProcessConfig: ProcessConfig{Hostname: "h"}},
+			want: []string{DefaultPathEnv, "HOSTNAME=user"},
+		},
+	}
+	for _, tt := range tests {
+		if got := ContainerEnv(&tt.info); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ContainerEnv() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package container
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/sys/unix"
@@ -11,55 +13,152 @@ import (
 	"strings"
 )
 
+// 容器启动失败时的退出码，init 启动用户命令失败时与 shell 一致
+const (
+	ExitCodeStartFailed  = 125 // 创建 Cgroup、连接网络等启动步骤失败
+	ExitCodeCannotInvoke = 126 // 命令无法执行
+	ExitCodeNotFound     = 127 // 命令不存在
+)
+
+// init 进程启动失败的错误，init 进程以 ExitCode 退出
+type InitError struct {
+	ExitCode int
+	Err      error
+}
+
+func (e *InitError) Error() string {
+	return e.Err.Error()
+}
+
+// init 进程通过 fd 3 读取启动配置，通过 fd 4 向父进程报告错误
 func RunContainerInitProcess() error {
-	cmdArray := readUserCommand()
-	if cmdArray == nil || len(cmdArray) == 0 {
-		return fmt.Errorf("run container get user command error, cmdArray is nil")
+	errorPipe := os.NewFile(uintptr(4), "error-pipe")
+	// execve 成功后错误管道自动关闭，父进程读到 EOF 即认为启动成功
+	unix.CloseOnExec(int(errorPipe.Fd()))
+	if err := runInit(); err != nil {
+		errorPipe.WriteString(err.Error())
+		errorPipe.Close()
+		if initErr, ok := err.(*InitError); ok {
+			return initErr
+		}
+		return &InitError{ExitCode: ExitCodeCannotInvoke, Err: err}
 	}
+	return nil
+}
 
-	setUpMount()
+func runInit() error {
+	spec, err := readInitSpec()
+	if err != nil {
+		return err
+	}
+	if len(spec.Args) == 0 {
+		return fmt.Errorf("run container get user command error, args is empty")
+	}
 
-	// 寻找命令的绝对路径
-	path, err := exec.LookPath(cmdArray[0])
+	if err := setUpMount(spec.Mounts); err != nil {
+		return err
+	}
+	setUpDev(spec.Devices)
+	if spec.Hostname != "" {
+		if err := unix.Sethostname([]byte(spec.Hostname)); err != nil {
+			return fmt.Errorf("set hostname error %v", err)
+		}
+	}
+	for _, rlimit := range spec.Rlimits {
+		limit := &unix.Rlimit{Cur: rlimit.Soft, Max: rlimit.Hard}
+		if err := unix.Setrlimit(rlimitTypes[rlimit.Type], limit); err != nil {
+			return fmt.Errorf("set rlimit %s error %v", rlimit.Type, err)
+		}
+	}
+	// 切换用户前创建工作目录，普通用户可能没有权限创建
+	if err := os.MkdirAll(spec.Cwd, 0755); err != nil {
+		return fmt.Errorf("create working directory %s error %v", spec.Cwd, err)
+	}
+	env := spec.Env
+	if spec.User != "" {
+		home, err := setUpUser(spec.User)
+		if err != nil {
+			return err
+		}
+		if home != "" && getEnv(env, "HOME") == "" {
+			env = append(env, "HOME=" + home)
+		}
+	}
+	if err := unix.Chdir(spec.Cwd); err != nil {
+		return fmt.Errorf("chdir to %s error %v", spec.Cwd, err)
+	}
+
+	// 在用户命令的 PATH 中寻找命令的绝对路径
+	os.Setenv("PATH", getEnv(env, "PATH"))
+	path, err := exec.LookPath(spec.Args[0])
 	if err != nil {
-		log.Errorf("exec loop path error %v", err)
+		exitCode := ExitCodeCannotInvoke
+		if errors.Is(err, exec.ErrNotFound) || errors.Is(err, os.ErrNotExist) {
+			exitCode = ExitCodeNotFound
+		}
+		return &InitError{ExitCode: exitCode, Err: fmt.Errorf("look up command %s error %v", spec.Args[0], err)}
 	}
 	log.Infof("find path %s", path)
-	if err := unix.Exec(path, cmdArray[0:], os.Environ()); err != nil {
-		log.Errorf(err.Error())
+	if err := unix.Exec(path, spec.Args, env); err != nil {
+		return fmt.Errorf("exec %s error %v", path, err)
 	}
 	return nil
 }
 
-// 读取用户命令
-func readUserCommand() []string {
+// 读取父进程发送的启动配置
+func readInitSpec() (*InitSpec, error) {
 	pipe := os.NewFile(uintptr(3), "pipe")
+	defer pipe.Close()
 	msg, err := ioutil.ReadAll(pipe)
 	if err != nil {
-		log.Errorf("init read pipe error %v", err)
-		return nil
+		return nil, fmt.Errorf("init read pipe error %v", err)
+	}
+	var spec InitSpec
+	if err := json.Unmarshal(msg, &spec); err != nil {
+		return nil, fmt.Errorf("unmarshal init spec error %v", err)
 	}
-	msgStr := string(msg)
-	return strings.Split(msgStr, " ")
+	if spec.Version != InitSpecVersion {
+		return nil, fmt.Errorf("unsupported init spec version %d, expected %d", spec.Version, InitSpecVersion)
+	}
+	return &spec, nil
+}
 
+// 从 KEY=VALUE 形式的环境变量中获取值
+func getEnv(env []string, key string) string {
+	for i := len(env) - 1; i >= 0; i-- {
+		if strings.HasPrefix(env[i], key + "=") {
+			return env[i][len(key)+1:]
+		}
+	}
+	return ""
 }
 
-func setUpMount()  {
+func setUpMount(mounts []Mount) error {
 	pwd, err := os.Getwd()
 	if err != nil {
-		log.Errorf("get current localtion error %v", err)
-		return
+		return fmt.Errorf("get current localtion error %v", err)
 	}
 	log.Infof("current location is %s", pwd)
 
 	unix.Mount("", "/", "", unix.MS_PRIVATE | unix.MS_REC, "")
 
-	pivotRoot(pwd)
+	if err := pivotRoot(pwd); err != nil {
+		return err
+	}
 
-	// MS_NOEXEC 不允许运行其他程序，MS_NOSUID 不允许 set-user-ID 或 set-group-ID，MS_NODEV 不允许访问设备
-	defaultMountFlags := unix.MS_NOEXEC | unix.MS_NOSUID | unix.MS_NODEV
-	unix.Mount("proc", "/proc", "proc", uintptr(defaultMountFlags), "")
-	unix.Mount("tmpfs", "/dev", "tmpfs", unix.MS_NOSUID | unix.MS_STRICTATIME, "mode=755")
+	for _, m := range mounts {
+		var flags uintptr
+		for _, flag := range m.Flags {
+			flags |= mountFlags[flag]
+		}
+		if err := os.MkdirAll(m.Destination, 0755); err != nil {
+			return fmt.Errorf("mkdir %s error %v", m.Destination, err)
+		}
+		if err := unix.Mount(m.Source, m.Destination, m.Type, flags, m.Data); err != nil {
+			return fmt.Errorf("mount %s to %s error %v", m.Source, m.Destination, err)
+		}
+	}
+	return nil
 }
 
 func pivotRoot(root string) error {
@@ -87,3 +186,35 @@ func pivotRoot(root string) error {
 	}
 	return os.Remove(pivotDir)
 }
+
+// 在 /dev 中创建标准设备、透传设备、伪终端和共享内存
+func setUpDev(devices []Device) {
+	for _, device := range append(DefaultDevices, devices...) {
+		if err := createDeviceNode(device); err != nil {
+			log.Errorf("create device %s error %v", device.Path, err)
+		}
+	}
+	// 每个容器使用单独的 devpts 实例，/dev/ptmx 指向其中的 ptmx
+	if err := os.MkdirAll("/dev/pts", 0755); err != nil {
+		log.Errorf("mkdir /dev/pts error %v", err)
+	} else if err := unix.Mount("devpts", "/dev/pts", "devpts", unix.MS_NOSUID | unix.MS_NOEXEC, "newinstance,ptmxmode=0666,mode=0620,gid=5"); err != nil {
+		log.Errorf("mount devpts error %v", err)
+	}
+	if err := os.MkdirAll("/dev/shm", 01777); err != nil {
+		log.Errorf("mkdir /dev/shm error %v", err)
+	} else if err := unix.Mount("shm", "/dev/shm", "tmpfs", unix.MS_NOSUID | unix.MS_NOEXEC | unix.MS_NODEV, "mode=1777,size=65536k"); err != nil {
+		log.Errorf("mount shm error %v", err)
+	}
+	links := [][2]string{
+		{"pts/ptmx", "/dev/ptmx"},
+		{"/proc/self/fd", "/dev/fd"},
+		{"/proc/self/fd/0", "/dev/stdin"},
+		{"/proc/self/fd/1", "/dev/stdout"},
+		{"/proc/self/fd/2", "/dev/stderr"},
+	}
+	for _, link := range links {
+		if err := os.Symlink(link[0], link[1]); err != nil && !os.IsExist(err) {
+			log.Errorf("create symlink %s error %v", link[1], err)
+		}
+	}
+}
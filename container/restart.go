@@ -0,0 +1,73 @@
+package container
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	RestartNo            = "no"
+	RestartAlways        = "always"
+	RestartOnFailure     = "on-failure"
+	RestartUnlessStopped = "unless-stopped"
+)
+
+// 容器重启策略
+type RestartPolicy struct {
+	Name              string `json:"name"`              // 策略名
+	MaximumRetryCount int    `json:"maximumRetryCount"` // on-failure 的最大重启次数，0 为不限制
+}
+
+// 解析重启策略字符串，格式为 no|always|on-failure[:N]|unless-stopped
+func ParseRestartPolicy(policy string) (RestartPolicy, error) {
+	if policy == "" {
+		return RestartPolicy{Name: RestartNo}, nil
+	}
+	parts := strings.SplitN(policy, ":", 2)
+	p := RestartPolicy{Name: parts[0]}
+	switch p.Name {
+	case RestartNo, RestartAlways, RestartUnlessStopped:
+		if len(parts) == 2 {
+			return p, fmt.Errorf("maximum retry count cannot be used with restart policy %s", p.Name)
+		}
+	case RestartOnFailure:
+		if len(parts) == 2 {
+			count, err := strconv.Atoi(parts[1])
+			if err != nil || count < 0 {
+				return p, fmt.Errorf("invalid maximum retry count %s", parts[1])
+			}
+			p.MaximumRetryCount = count
+		}
+	default:
+		return p, fmt.Errorf("invalid restart policy %s", policy)
+	}
+	return p, nil
+}
+
+// 判断容器退出后是否需要重启，手动停止的容器不再重启
+func (p RestartPolicy) ShouldRestart(exitCode, restartCount int, manuallyStopped bool) bool {
+	if manuallyStopped {
+		return false
+	}
+	switch p.Name {
+	case RestartAlways, RestartUnlessStopped:
+		return true
+	case RestartOnFailure:
+		if exitCode == 0 {
+			return false
+		}
+		return p.MaximumRetryCount == 0 || restartCount < p.MaximumRetryCount
+	}
+	return false
+}
+
+func (p RestartPolicy) String() string {
+	if p.Name == RestartOnFailure && p.MaximumRetryCount > 0 {
+		return fmt.Sprintf("%s:%d", p.Name, p.MaximumRetryCount)
+	}
+	if p.Name == "" {
+		return RestartNo
+	}
+	return p.Name
+}
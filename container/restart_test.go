@@ -0,0 +1,83 @@
+package container
+
+import "testing"
+
+func TestParseRestartPolicy(t *testing.T) {
+	tests := []struct {
+		policy  string
+		want    RestartPolicy
+		wantErr bool
+	}{
+		{policy: "", want: RestartPolicy{Name: RestartNo}},
+		{policy: "no", want: RestartPolicy{Name: RestartNo}},
+		{policy: "always", want: RestartPolicy{Name: RestartAlways}},
+		{policy: "unless-stopped", want: RestartPolicy{Name: RestartUnlessStopped}},
+		{policy: "on-failure", want: RestartPolicy{Name: RestartOnFailure}},
+		{policy: "on-failure:3", want: RestartPolicy{Name: RestartOnFailure, MaximumRetryCount: 3}},
+		{policy: "on-failure:0", want: RestartPolicy{Name: RestartOnFailure}},
+		{policy: "on-failure:-1", wantErr: true},
+		{policy: "on-failure:abc", wantErr: true},
+		{policy: "on-failure:", wantErr: true},
+		{policy: "always:3", wantErr: true},
+		{policy: "no:1", wantErr: true},
+		{policy: "unless-stopped:1", wantErr: true},
+		{policy: "sometimes", wantErr: true},
+		{policy: "Always", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRestartPolicy(tt.policy)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRestartPolicy(%q) error = %v, wantErr %v", tt.policy, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseRestartPolicy(%q) = %+v, want %+v", tt.policy, got, tt.want)
+		}
+	}
+}
+
+func TestShouldRestart(t *testing.T) {
+	tests := []struct {
+		policy          RestartPolicy
+		exitCode        int
+		restartCount    int
+		manuallyStopped bool
+		want            bool
+	}{
+		{policy: RestartPolicy{Name: RestartNo}, exitCode: 1, want: false},
+		{policy: RestartPolicy{}, exitCode: 1, want: false},
+		{policy: RestartPolicy{Name: RestartAlways}, exitCode: 0, want: true},
+		{policy: RestartPolicy{Name: RestartAlways}, exitCode: 1, restartCount: 100, want: true},
+		{policy: RestartPolicy{Name: RestartAlways}, exitCode: 1, manuallyStopped: true, want: false},
+		{policy: RestartPolicy{Name: RestartUnlessStopped}, exitCode: 0, want: true},
+		{policy: RestartPolicy{Name: RestartUnlessStopped}, exitCode: 0, manuallyStopped: true, want: false},
+		{policy: RestartPolicy{Name: RestartOnFailure}, exitCode: 0, want: false},
+		{policy: RestartPolicy{Name: RestartOnFailure}, exitCode: 137, restartCount: 100, want: true},
+		{policy: RestartPolicy{Name: RestartOnFailure, MaximumRetryCount: 2}, exitCode: 1, restartCount: 1, want: true},
+		{policy: RestartPolicy{Name: RestartOnFailure, MaximumRetryCount: 2}, exitCode: 1, restartCount: 2, want: false},
+		{policy: RestartPolicy{Name: RestartOnFailure, MaximumRetryCount: 2}, exitCode: 1, manuallyStopped: true, want: false},
+	}
+	for _, tt := range tests {
+		got := tt.policy.ShouldRestart(tt.exitCode, tt.restartCount, tt.manuallyStopped)
+		if got != tt.want {
+			t.Errorf("%+v.ShouldRestart(%d, %d, %v) = %v, want %v", tt.policy, tt.exitCode, tt.restartCount, tt.manuallyStopped, got, tt.want)
+		}
+	}
+}
+
+func TestRestartPolicyString(t *testing.T) {
+	tests := []struct {
+		policy RestartPolicy
+		want   string
+	}{
+		{policy: RestartPolicy{}, want: "no"},
+		{policy: RestartPolicy{Name: RestartAlways}, want: "always"},
+		{policy: RestartPolicy{Name: RestartOnFailure}, want: "on-failure"},
+		{policy: RestartPolicy{Name: RestartOnFailure, MaximumRetryCount: 5}, want: "on-failure:5"},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.policy, got, tt.want)
+		}
+	}
+}
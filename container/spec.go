@@ -0,0 +1,166 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// init 协议的版本，父进程和 init 进程版本不一致时拒绝启动
+const InitSpecVersion = 1
+
+// 父进程通过 fd 3 发送给容器 init 进程的启动配置
+type InitSpec struct {
+	Version  int      `json:"version"`
+	Args     []string `json:"args"`     // 用户命令及参数，原样传给 execve
+	Env      []string `json:"env"`      // 用户命令的环境变量
+	Cwd      string   `json:"cwd"`      // 用户命令的工作目录
+	User     string   `json:"user"`     // 运行用户，格式为 user[:group]，可以是名字或 ID
+	Hostname string   `json:"hostname"` // 容器主机名
+	Mounts   []Mount  `json:"mounts"`   // pivot_root 之后需要挂载的文件系统
+	Rlimits  []Rlimit `json:"rlimits"`  // 用户命令的资源限制
+	Devices  []Device `json:"devices"`  // 透传的宿主机设备
+}
+
+// 容器内的挂载
+type Mount struct {
+	Source      string   `json:"source"`
+	Destination string   `json:"destination"`
+	Type        string   `json:"type"`
+	Flags       []string `json:"flags"` // 挂载选项，如 nosuid、noexec
+	Data        string   `json:"data"`  // 传给文件系统的参数
+}
+
+// 进程资源限制
+type Rlimit struct {
+	Type string `json:"type"` // 限制名，如 nofile
+	Soft uint64 `json:"soft"`
+	Hard uint64 `json:"hard"`
+}
+
+// 容器 init 进程的运行配置
+type ProcessConfig struct {
+	Hostname   string   `json:"hostname"`   // 主机名
+	User       string   `json:"user"`       // 运行用户
+	WorkingDir string   `json:"workingDir"` // 工作目录
+	Rlimits    []Rlimit `json:"rlimits"`    // 资源限制
+}
+
+var (
+	// pivot_root 之后挂载的 proc 和 /dev
+	DefaultMounts = []Mount{
+		{Source: "proc", Destination: "/proc", Type: "proc", Flags: []string{"noexec", "nosuid", "nodev"}},
+		{Source: "tmpfs", Destination: "/dev", Type: "tmpfs", Flags: []string{"nosuid", "strictatime"}, Data: "mode=755"},
+	}
+	mountFlags = map[string]uintptr{
+		"ro":          unix.MS_RDONLY,
+		"nosuid":      unix.MS_NOSUID,
+		"nodev":       unix.MS_NODEV,
+		"noexec":      unix.MS_NOEXEC,
+		"strictatime": unix.MS_STRICTATIME,
+		"relatime":    unix.MS_RELATIME,
+		"noatime":     unix.MS_NOATIME,
+		"bind":        unix.MS_BIND,
+		"rbind":       unix.MS_BIND | unix.MS_REC,
+	}
+	rlimitTypes = map[string]int{
+		"as":         unix.RLIMIT_AS,
+		"core":       unix.RLIMIT_CORE,
+		"cpu":        unix.RLIMIT_CPU,
+		"data":       unix.RLIMIT_DATA,
+		"fsize":      unix.RLIMIT_FSIZE,
+		"locks":      unix.RLIMIT_LOCKS,
+		"memlock":    unix.RLIMIT_MEMLOCK,
+		"msgqueue":   unix.RLIMIT_MSGQUEUE,
+		"nice":       unix.RLIMIT_NICE,
+		"nofile":     unix.RLIMIT_NOFILE,
+		"nproc":      unix.RLIMIT_NPROC,
+		"rss":        unix.RLIMIT_RSS,
+		"rtprio":     unix.RLIMIT_RTPRIO,
+		"rttime":     unix.RLIMIT_RTTIME,
+		"sigpending": unix.RLIMIT_SIGPENDING,
+		"stack":      unix.RLIMIT_STACK,
+	}
+)
+
+// 根据容器信息生成 init 进程的启动配置
+func NewInitSpec(containerInfo *ContainerInfo) *InitSpec {
+	cwd := containerInfo.WorkingDir
+	if cwd == "" {
+		cwd = "/"
+	}
+	return &InitSpec{
+		Version:  InitSpecVersion,
+		Args:     containerInfo.Args,
+		Env:      ContainerEnv(containerInfo),
+		Cwd:      cwd,
+		User:     containerInfo.User,
+		Hostname: containerInfo.Hostname,
+		Mounts:   DefaultMounts,
+		Rlimits:  containerInfo.Rlimits,
+		Devices:  containerInfo.Devices,
+	}
+}
+
+// 解析 --ulimit 参数，格式为 <name>=<soft>[:<hard>]，-1 表示不限制
+func ParseRlimit(value string) (*Rlimit, error) {
+	kv := strings.SplitN(value, "=", 2)
+	if len(kv) != 2 {
+		return nil, fmt.Errorf("bad format of ulimit %s, expected <name>=<soft>[:<hard>]", value)
+	}
+	if _, ok := rlimitTypes[kv[0]]; !ok {
+		return nil, fmt.Errorf("invalid ulimit type %s", kv[0])
+	}
+	limits := strings.SplitN(kv[1], ":", 2)
+	soft, err := parseRlimitValue(limits[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid ulimit %s", value)
+	}
+	hard := soft
+	if len(limits) == 2 {
+		if hard, err = parseRlimitValue(limits[1]); err != nil {
+			return nil, fmt.Errorf("invalid ulimit %s", value)
+		}
+	}
+	if soft > hard {
+		return nil, fmt.Errorf("ulimit soft limit %d is larger than hard limit %d", soft, hard)
+	}
+	return &Rlimit{Type: kv[0], Soft: soft, Hard: hard}, nil
+}
+
+func parseRlimitValue(value string) (uint64, error) {
+	if value == "-1" || value == "unlimited" {
+		return unix.RLIM_INFINITY, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// 将启动配置写入管道并关闭写入端，init 进程读到 EOF 后开始启动
+func SendInitSpec(spec *InitSpec, writePipe *os.File) error {
+	defer writePipe.Close()
+	specBytes, err := json.Marshal(spec)
+	if err != nil {
+		return fmt.Errorf("json marshal init spec error %v", err)
+	}
+	if _, err := writePipe.Write(specBytes); err != nil {
+		return fmt.Errorf("write init spec error %v", err)
+	}
+	return nil
+}
+
+// 等待 init 进程执行用户命令，错误管道在 execve 成功时随 CLOEXEC 关闭，失败时 init 写入错误信息
+func WaitInitError(errorPipe *os.File) error {
+	defer errorPipe.Close()
+	msg, err := ioutil.ReadAll(errorPipe)
+	if err != nil {
+		return fmt.Errorf("read init error pipe error %v", err)
+	}
+	if len(msg) > 0 {
+		return fmt.Errorf("%s", msg)
+	}
+	return nil
+}
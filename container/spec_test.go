@@ -0,0 +1,86 @@
+package container
+
+import (
+	"golang.org/x/sys/unix"
+	"reflect"
+	"testing"
+)
+
+func TestParseRlimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    *Rlimit
+		wantErr bool
+	}{
+		{value: "nofile=1024", want: &Rlimit{Type: "nofile", Soft: 1024, Hard: 1024}},
+		{value: "nofile=1024:2048", want: &Rlimit{Type: "nofile", Soft: 1024, Hard: 2048}},
+		{value: "nofile=1024:1024", want: &Rlimit{Type: "nofile", Soft: 1024, Hard: 1024}},
+		{value: "nproc=0", want: &Rlimit{Type: "nproc", Soft: 0, Hard: 0}},
+		{value: "core=-1", want: &Rlimit{Type: "core", Soft: unix.RLIM_INFINITY, Hard: unix.RLIM_INFINITY}},
+		{value: "core=unlimited", want: &Rlimit{Type: "core", Soft: unix.RLIM_INFINITY, Hard: unix.RLIM_INFINITY}},
+		{value: "core=0:-1", want: &Rlimit{Type: "core", Soft: 0, Hard: unix.RLIM_INFINITY}},
+		{value: "core=-1:unlimited", want: &Rlimit{Type: "core", Soft: unix.RLIM_INFINITY, Hard: unix.RLIM_INFINITY}},
+		{value: "stack=18446744073709551615", want: &Rlimit{Type: "stack", Soft: unix.RLIM_INFINITY, Hard: unix.RLIM_INFINITY}},
+		// 软限制大于硬限制
+		{value: "nofile=2048:1024", wantErr: true},
+		{value: "nofile=-1:1024", wantErr: true},
+		{value: "nofile=unlimited:0", wantErr: true},
+		// 格式错误
+		{value: "nofile", wantErr: true},
+		{value: "nofile=", wantErr: true},
+		{value: "nofile=:1024", wantErr: true},
+		{value: "nofile=1024:", wantErr: true},
+		{value: "nofile=1:2:3", wantErr: true},
+		{value: "nofile=abc", wantErr: true},
+		{value: "nofile=-2", wantErr: true},
+		{value: "nofile=1.5", wantErr: true},
+		{value: "nofile=18446744073709551616", wantErr: true},
+		{value: "=1024", wantErr: true},
+		{value: "bogus=1024", wantErr: true},
+		{value: "NOFILE=1024", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRlimit(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRlimit(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseRlimit(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestNewInitSpec(t *testing.T) {
+	info := &ContainerInfo{
+		Args: []string{"sh", "-c", "echo hello world"},
+		Env:  []string{DefaultPathEnv, "A=1"},
+		ProcessConfig: ProcessConfig{
+			Hostname: "box",
+			User:     "nobody",
+			Rlimits:  []Rlimit{{Type: "nofile", Soft: 1, Hard: 2}},
+		},
+	}
+	spec := NewInitSpec(info)
+	if spec.Version != InitSpecVersion {
+		t.Errorf("Version = %d, want %d", spec.Version, InitSpecVersion)
+	}
+	// 参数原样传递，不按空格拆分
+	if !reflect.DeepEqual(spec.Args, info.Args) {
+		t.Errorf("Args = %q, want %q", spec.Args, info.Args)
+	}
+	if !reflect.DeepEqual(spec.Env, info.Env) {
+		t.Errorf("Env = %q, want %q", spec.Env, info.Env)
+	}
+	if spec.Cwd != "/" {
+		t.Errorf("Cwd = %q, want /", spec.Cwd)
+	}
+	if spec.Hostname != "box" || spec.User != "nobody" || !reflect.DeepEqual(spec.Rlimits, info.Rlimits) {
+		t.Errorf("process config not copied: %+v", spec)
+	}
+
+	info.WorkingDir = "/work"
+	if spec := NewInitSpec(info); spec.Cwd != "/work" {
+		t.Errorf("Cwd = %q, want /work", spec.Cwd)
+	}
+}
@@ -0,0 +1,132 @@
+package container
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// passwd 文件中的用户
+type PasswdEntry struct {
+	Name string
+	Uid  int
+	Gid  int
+	Home string
+}
+
+// group 文件中的用户组
+type groupEntry struct {
+	Name    string
+	Gid     int
+	Members []string
+}
+
+// 切换到 user[:group] 指定的用户，用户名和组名在容器的 /etc/passwd 和 /etc/group 中查找，返回用户的 home 目录
+func setUpUser(user string) (string, error) {
+	parts := strings.SplitN(user, ":", 2)
+	users := ReadPasswdFile("/etc/passwd")
+	groups := readGroupFile("/etc/group")
+
+	var entry *PasswdEntry
+	for i := range users {
+		if users[i].Name == parts[0] || strconv.Itoa(users[i].Uid) == parts[0] {
+			entry = &users[i]
+			break
+		}
+	}
+	// 不在 passwd 中的用户只能使用数字 UID
+	if entry == nil {
+		uid, err := strconv.Atoi(parts[0])
+		if err != nil || uid < 0 {
+			return "", fmt.Errorf("unable to find user %s: no matching entries in passwd file", parts[0])
+		}
+		entry = &PasswdEntry{Uid: uid, Home: "/"}
+	}
+
+	gid := entry.Gid
+	if len(parts) == 2 {
+		found := false
+		for _, group := range groups {
+			if group.Name == parts[1] || strconv.Itoa(group.Gid) == parts[1] {
+				gid, found = group.Gid, true
+				break
+			}
+		}
+		if !found {
+			var err error
+			if gid, err = strconv.Atoi(parts[1]); err != nil || gid < 0 {
+				return "", fmt.Errorf("unable to find group %s: no matching entries in group file", parts[1])
+			}
+		}
+	}
+
+	// 附加组为 group 文件中包含该用户的组
+	supplementary := []int{gid}
+	for _, group := range groups {
+		for _, member := range group.Members {
+			if entry.Name != "" && member == entry.Name && group.Gid != gid {
+				supplementary = append(supplementary, group.Gid)
+			}
+		}
+	}
+	// 先设置组再设置用户，切换用户后没有权限修改组
+	if err := syscall.Setgroups(supplementary); err != nil {
+		return "", fmt.Errorf("setgroups error %v", err)
+	}
+	if err := syscall.Setgid(gid); err != nil {
+		return "", fmt.Errorf("setgid %d error %v", gid, err)
+	}
+	if err := syscall.Setuid(entry.Uid); err != nil {
+		return "", fmt.Errorf("setuid %d error %v", entry.Uid, err)
+	}
+	return entry.Home, nil
+}
+
+// 解析 name:password:uid:gid:gecos:home:shell 格式的 passwd 文件，文件不存在时返回 nil
+func ReadPasswdFile(passwdPath string) []PasswdEntry {
+	content, err := ioutil.ReadFile(passwdPath)
+	if err != nil {
+		return nil
+	}
+	var users []PasswdEntry
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) < 6 {
+			continue
+		}
+		uid, err := strconv.Atoi(fields[2])
+		if err != nil {
+			continue
+		}
+		gid, _ := strconv.Atoi(fields[3])
+		users = append(users, PasswdEntry{Name: fields[0], Uid: uid, Gid: gid, Home: fields[5]})
+	}
+	return users
+}
+
+// 解析 name:password:gid:members 格式的 group 文件
+func readGroupFile(groupPath string) []groupEntry {
+	content, err := ioutil.ReadFile(groupPath)
+	if err != nil {
+		return nil
+	}
+	var groups []groupEntry
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) < 4 {
+			continue
+		}
+		gid, err := strconv.Atoi(fields[2])
+		if err != nil {
+			continue
+		}
+		var members []string
+		if fields[3] != "" {
+			members = strings.Split(fields[3], ",")
+		}
+		groups = append(groups, groupEntry{Name: fields[0], Gid: gid, Members: members})
+	}
+	return groups
+}
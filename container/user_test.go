@@ -0,0 +1,75 @@
+package container
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeTempFile(t *testing.T, dir, name, content string) string {
+	file := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestReadPasswdFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lumper-user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	passwd := writeTempFile(t, dir, "passwd", `root:x:0:0:root:/root:/bin/sh
+daemon:x:1:1:daemon:/usr/sbin:/usr/sbin/nologin
+
+short:x:5:5
+baduid:x:abc:5::/home/baduid:/bin/sh
+badgid:x:7:abc::/home/badgid:/bin/sh
+nobody:x:65534:65534:nobody:/nonexistent:/usr/sbin/nologin
+noshell:x:8:8::/home/noshell`)
+	want := []PasswdEntry{
+		{Name: "root", Uid: 0, Gid: 0, Home: "/root"},
+		{Name: "daemon", Uid: 1, Gid: 1, Home: "/usr/sbin"},
+		{Name: "badgid", Uid: 7, Gid: 0, Home: "/home/badgid"},
+		{Name: "nobody", Uid: 65534, Gid: 65534, Home: "/nonexistent"},
+		{Name: "noshell", Uid: 8, Gid: 8, Home: "/home/noshell"},
+	}
+	if got := ReadPasswdFile(passwd); !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadPasswdFile() = %+v, want %+v", got, want)
+	}
+	if got := ReadPasswdFile(filepath.Join(dir, "missing")); got != nil {
+		t.Errorf("ReadPasswdFile() of missing file = %+v, want nil", got)
+	}
+}
+
+func TestReadGroupFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lumper-group")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	group := writeTempFile(t, dir, "group", `root:x:0:
+wheel:x:10:root,alice
+users:x:100:bob
+
+bad:x:abc:alice
+short:x:5
+audio:x:29:alice`)
+	want := []groupEntry{
+		{Name: "root", Gid: 0},
+		{Name: "wheel", Gid: 10, Members: []string{"root", "alice"}},
+		{Name: "users", Gid: 100, Members: []string{"bob"}},
+		{Name: "audio", Gid: 29, Members: []string{"alice"}},
+	}
+	if got := readGroupFile(group); !reflect.DeepEqual(got, want) {
+		t.Errorf("readGroupFile() = %+v, want %+v", got, want)
+	}
+	if got := readGroupFile(filepath.Join(dir, "missing")); got != nil {
+		t.Errorf("readGroupFile() of missing file = %+v, want nil", got)
+	}
+}
@@ -1,14 +1,36 @@
 package container
 
 import (
+	"bufio"
 	"fmt"
 	"golang.org/x/sys/unix"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
 	"strings"
 	log "github.com/sirupsen/logrus"
 )
 
+// 容器工作空间中 overlay2 各层的目录
+type WorkSpace struct {
+	LowerDir  string `json:"lowerDir"`  // 只读层
+	UpperDir  string `json:"upperDir"`  // 可写层
+	WorkDir   string `json:"workDir"`   // overlay2 工作目录
+	MergedDir string `json:"mergedDir"` // 挂载点
+}
+
+// 获取容器工作空间的各层目录
+func GetWorkSpace(containerName, imageName string) WorkSpace {
+	containerUrl := fmt.Sprintf(Overlay2Location, containerName)
+	return WorkSpace{
+		LowerDir:  fmt.Sprintf(ImageLocation, imageName),
+		UpperDir:  containerUrl + "upper",
+		WorkDir:   containerUrl + "work",
+		MergedDir: containerUrl + "merged",
+	}
+}
+
 func NewWorkSpace(volume , containerName, imageName string) {
 	CreateReadOnlyLayer(imageName)
 	CreateWriteLayer(containerName)
@@ -73,6 +95,10 @@ func CreateMountPoint(containerName, imageName string) error {
 	}
 	upperUrl := containerUrl + "upper"
 	workUrl := containerUrl + "work"
+	// 重新启动容器时挂载点可能仍然存在，不需要重复挂载
+	if IsMountPoint(mergeUrl) {
+		return nil
+	}
 	tmpImageLocation := fmt.Sprintf(ImageLocation, imageName)
 	// 把 writeLayer 目录和 busybox 目录挂载到 mnt 目录下
 	dirs := "lowerdir=" + tmpImageLocation +",upperdir=" + upperUrl + ",workdir=" + workUrl
@@ -83,29 +109,29 @@ func CreateMountPoint(containerName, imageName string) error {
 	return nil
 }
 
-func DeleteWorkSpace(volume, containerName, imageName string) {
-	if (volume != "") {
-		volumeUrls := volumeUrlExtract(volume)
-		length := len(volumeUrls)
-		if(length == 2 && volumeUrls[0] != "" && volumeUrls[1] != "") {
-			DeleteMountPointWithVolume(volumeUrls, containerName)
-		} else {
-			DeleteMountPoint(containerName)
+// 删除容器工作空间，挂载点卸载失败时保留容器文件夹，避免删除数据卷中的数据
+func DeleteWorkSpace(volume, containerName, imageName string) error {
+	if volumeUrls := ParseVolume(volume); volumeUrls != nil {
+		if err := DeleteMountPointWithVolume(volumeUrls, containerName); err != nil {
+			return err
 		}
-	} else {
-		DeleteMountPoint(containerName)
+	} else if err := DeleteMountPoint(containerName); err != nil {
+		return err
 	}
 	DeleteContainerFolder(containerName)
+	return nil
 }
 
 // 删除挂载点
 func DeleteMountPoint(containerName string) error {
 	containerUrl := fmt.Sprintf(Overlay2Location, containerName)
 	mergedUrl := containerUrl + "merged"
-	// 卸载挂载点
-	if err := unix.Unmount(mergedUrl, unix.MNT_FORCE) ; err != nil {
-		log.Errorf("umount merged floder failed %v", err)
-		return err
+	// 卸载挂载点，容器被重复清理时可能已经卸载
+	if IsMountPoint(mergedUrl) {
+		if err := unix.Unmount(mergedUrl, unix.MNT_FORCE) ; err != nil {
+			log.Errorf("umount merged floder failed %v", err)
+			return err
+		}
 	}
 	// 删除 mnt 文件夹
 	if err := os.RemoveAll(mergedUrl); err != nil {
@@ -121,20 +147,13 @@ func DeleteMountPointWithVolume(volumeUrls []string, containerName string) error
 	mergedUrl := containerUrl + "merged"
 	containerVolumeUrl := mergedUrl + volumeUrls[1]
 	// 卸载 volume
-	if _, err := exec.Command("umount", containerVolumeUrl).CombinedOutput(); err != nil {
-		log.Errorf("umount volume failed %v", err)
-		return err
-	}
-	// 卸载挂载点
-	if _, err := exec.Command("umount", mergedUrl).CombinedOutput(); err != nil {
-		log.Errorf("umount merged floder failed %v", err)
-		return err
-	}
-	// 删除 mnt 文件夹
-	if err := os.RemoveAll(mergedUrl); err != nil {
-		log.Infof("remove merged floder %s error", mergedUrl, err)
+	if IsMountPoint(containerVolumeUrl) {
+		if _, err := exec.Command("umount", containerVolumeUrl).CombinedOutput(); err != nil {
+			log.Errorf("umount volume failed %v", err)
+			return err
+		}
 	}
-	return nil
+	return DeleteMountPoint(containerName)
 }
 
 // 删除容器文件夹
@@ -152,14 +171,17 @@ func MountVolume(volumeUrls []string, containerName string) error {
 	exist, _ := PathExists(parentUrl)
 	if !exist {
 		// 创建宿主机文件目录
-		if err := os.Mkdir(parentUrl, 0777); err != nil {
+		if err := os.MkdirAll(parentUrl, 0777); err != nil {
 			log.Errorf("mkdir parent dir %s error %v", parentUrl, err)
 		}
 	}
 	// 在容器文件系统中创建挂载点
 	containerUrl := fmt.Sprintf(Overlay2Location, containerName)
 	containerVolumeUrl := containerUrl + "merged" + volumeUrls[1]
-	if err := os.Mkdir(containerVolumeUrl, 0777); err != nil {
+	if IsMountPoint(containerVolumeUrl) {
+		return nil
+	}
+	if err := os.MkdirAll(containerVolumeUrl, 0777); err != nil {
 		log.Errorf("mkdir container dir %s error %v", containerVolumeUrl, err)
 	}
 	// 把宿主机文件目录挂载到容器挂载点
@@ -183,6 +205,70 @@ func PathExists(path string) (bool, error) {
 	return false, err
 }
 
+// 判断路径是否为挂载点
+func IsMountPoint(path string) bool {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	path = filepath.Clean(path)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 第 5 列为挂载点
+		fields := strings.Split(scanner.Text(), " ")
+		if len(fields) > 4 && fields[4] == path {
+			return true
+		}
+	}
+	return false
+}
+
+// 获取路径下的所有挂载点，子挂载点排在前面，便于依次卸载
+func GetMountPoints(path string) []string {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	path = filepath.Clean(path)
+	var mountPoints []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), " ")
+		if len(fields) > 4 && (fields[4] == path || strings.HasPrefix(fields[4], path + "/")) {
+			mountPoints = append(mountPoints, fields[4])
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(mountPoints)))
+	return mountPoints
+}
+
+// 只指定容器内目录的匿名数据卷，在宿主机上创建以容器名命名的目录
+func NormalizeVolume(volume, containerName string) string {
+	if volume == "" || strings.Contains(volume, ":") {
+		return volume
+	}
+	return fmt.Sprintf(VolumeLocation, containerName) + ":" + volume
+}
+
+// 判断宿主机目录是否为匿名数据卷
+func IsAnonymousVolume(hostUrl string) bool {
+	volumeRoot := filepath.Clean(fmt.Sprintf(VolumeLocation, ""))
+	return filepath.Dir(filepath.Clean(hostUrl)) == volumeRoot
+}
+
+// 解析 volume 字符串，返回宿主机目录和容器内目录，格式不正确时返回 nil
+func ParseVolume(volume string) []string {
+	volumeUrls := volumeUrlExtract(volume)
+	if len(volumeUrls) == 2 && volumeUrls[0] != "" && volumeUrls[1] != "" {
+		return volumeUrls
+	}
+	return nil
+}
+
 // 解析 volume 字符串
 func volumeUrlExtract(volume string) ([]string) {
 	var volumeUrls []string
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	"golang.org/x/sys/unix"
+	"io"
+	"lumper/container"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// 事件日志，每行一个 JSON 格式的事件，只追加不修改
+	eventsLocation = "/var/lib/lumper/events.jsonl"
+	// 跟踪新事件时轮询事件日志的时间间隔
+	eventsPollInterval = 100 * time.Millisecond
+)
+
+const (
+	eventTypeContainer = "container"
+	eventTypeNetwork   = "network"
+)
+
+var eventsCommand = cli.Command{
+	Name:   "events",
+	Usage:  "Get real time events from containers",
+	Flags:  []cli.Flag{
+		cli.StringFlag{
+			Name:  "since",
+			Usage: "show events created since timestamp, RFC3339, unix timestamp or duration like 10m",
+		},
+		cli.StringFlag{
+			Name:  "until",
+			Usage: "stream events until timestamp, RFC3339, unix timestamp or duration like 10m",
+		},
+		cli.StringSliceFlag{
+			Name:  "filter",
+			Usage: "filter output, type=|event=|container=|image=|network=",
+		},
+		cli.StringFlag{
+			Name:  "format",
+			Usage: "output format, json|Go template",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		now := time.Now()
+		var since, until time.Time
+		var err error
+		if value := context.String("since"); value != "" {
+			if since, err = parseEventTime(value, now); err != nil {
+				return err
+			}
+		}
+		if value := context.String("until"); value != "" {
+			if until, err = parseEventTime(value, now); err != nil {
+				return err
+			}
+		}
+		filters, err := parseEventFilters(context.StringSlice("filter"))
+		if err != nil {
+			return err
+		}
+		return streamEvents(since, until, filters, context.String("format"))
+	},
+}
+
+// 容器生命周期中的事件
+type event struct {
+	Type       string            `json:"type"`       // 事件对象类型，container 或 network
+	Action     string            `json:"action"`     // 事件动作，如 start、die
+	ID         string            `json:"id"`         // 容器 ID 或网络名
+	Attributes map[string]string `json:"attributes"` // 事件属性，如容器名、退出码
+	Time       time.Time         `json:"time"`       // 事件发生时间
+}
+
+// 记录容器事件
+func logContainerEvent(action string, containerInfo *container.ContainerInfo, attributes map[string]string) {
+	attrs := map[string]string{
+		"name":  containerInfo.Name,
+		"image": containerInfo.Image,
+	}
+	for key, value := range containerInfo.Labels {
+		attrs["label." + key] = value
+	}
+	for key, value := range attributes {
+		attrs[key] = value
+	}
+	logEvent(&event{
+		Type:       eventTypeContainer,
+		Action:     action,
+		ID:         containerInfo.Id,
+		Attributes: attrs,
+	})
+}
+
+// 记录容器连接或断开网络的事件
+func logNetworkEvent(action string, containerInfo *container.ContainerInfo) {
+	logEvent(&event{
+		Type:   eventTypeNetwork,
+		Action: action,
+		ID:     containerInfo.Network,
+		Attributes: map[string]string{
+			"container": containerInfo.Id,
+			"name":      containerInfo.Name,
+		},
+	})
+}
+
+// 将事件追加到事件日志，记录失败不影响容器操作
+func logEvent(e *event) {
+	e.Time = time.Now()
+	jsonBytes, err := json.Marshal(e)
+	if err != nil {
+		log.Errorf("json marshal event error %v", err)
+		return
+	}
+	if err := os.MkdirAll(path.Dir(eventsLocation), 0755); err != nil {
+		log.Errorf("mkdir %s error %v", path.Dir(eventsLocation), err)
+		return
+	}
+	file, err := os.OpenFile(eventsLocation, os.O_CREATE | os.O_WRONLY | os.O_APPEND, 0644)
+	if err != nil {
+		log.Errorf("open file %s error %v", eventsLocation, err)
+		return
+	}
+	defer file.Close()
+	// 多个 lumper 进程可能同时写入，加锁保证每行完整
+	if err := unix.Flock(int(file.Fd()), unix.LOCK_EX); err != nil {
+		log.Errorf("lock file %s error %v", eventsLocation, err)
+		return
+	}
+	defer unix.Flock(int(file.Fd()), unix.LOCK_UN)
+	if _, err := file.Write(append(jsonBytes, '\n')); err != nil {
+		log.Errorf("write event error %v", err)
+	}
+}
+
+// 解析时间，支持 RFC3339、unix 时间戳和相对当前时间的时长
+func parseEventTime(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	if t, ok := parseUnixTime(value); ok {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %s", value)
+}
+
+// 解析 unix 时间戳，秒和纳秒分开解析，避免浮点数丢失精度
+func parseUnixTime(value string) (time.Time, bool) {
+	parts := strings.SplitN(value, ".", 2)
+	seconds, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	var nanos int64
+	if len(parts) == 2 {
+		fraction := parts[1]
+		if fraction == "" || len(fraction) > 9 || strings.Trim(fraction, "0123456789") != "" {
+			return time.Time{}, false
+		}
+		if nanos, err = strconv.ParseInt(fraction + strings.Repeat("0", 9 - len(fraction)), 10, 64); err != nil {
+			return time.Time{}, false
+		}
+	}
+	return time.Unix(seconds, nanos), true
+}
+
+// 解析 key=value 形式的过滤条件，同一个 key 的多个值之间为或的关系
+func parseEventFilters(rawFilters []string) (map[string][]string, error) {
+	filters := map[string][]string{}
+	for _, rawFilter := range rawFilters {
+		kv := strings.SplitN(rawFilter, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("bad format of filter %s, expected key=value", rawFilter)
+		}
+		switch kv[0] {
+		case "type", "event", "container", "image", "network":
+		default:
+			return nil, fmt.Errorf("invalid filter %s", kv[0])
+		}
+		filters[kv[0]] = append(filters[kv[0]], kv[1])
+	}
+	return filters, nil
+}
+
+func matchEventFilters(e *event, filters map[string][]string) bool {
+	for key, values := range filters {
+		matched := false
+		for _, value := range values {
+			switch key {
+			case "type":
+				matched = e.Type == value
+			case "event":
+				matched = e.Action == value
+			case "container":
+				if e.Type == eventTypeContainer {
+					matched = e.ID == value || e.Attributes["name"] == value
+				} else {
+					matched = e.Attributes["container"] == value || e.Attributes["name"] == value
+				}
+			case "image":
+				matched = e.Attributes["image"] == value
+			case "network":
+				matched = e.Type == eventTypeNetwork && e.ID == value
+			}
+			if matched {
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// 输出事件日志中的历史事件，没有指定 until 时继续跟踪新事件
+func streamEvents(since, until time.Time, filters map[string][]string, format string) error {
+	var tmpl *template.Template
+	if format != "" && format != "json" {
+		var err error
+		if tmpl, err = template.New("events").Funcs(templateFuncs).Parse(format); err != nil {
+			return fmt.Errorf("parse format error %v", err)
+		}
+	}
+	file, err := os.OpenFile(eventsLocation, os.O_CREATE | os.O_RDONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("open file %s error %v", eventsLocation, err)
+	}
+	defer file.Close()
+	// 没有指定 since 时只输出新事件
+	if since.IsZero() && until.IsZero() {
+		if _, err := file.Seek(0, io.SeekEnd); err != nil {
+			return err
+		}
+	}
+
+	reader := bufio.NewReader(file)
+	var partial string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("read events error %v", err)
+		}
+		// 写入者可能还没有写完一行，保留到下次读取
+		if err == io.EOF {
+			partial += line
+			if !until.IsZero() && !time.Now().Before(until) {
+				return nil
+			}
+			time.Sleep(eventsPollInterval)
+			continue
+		}
+		line, partial = partial + line, ""
+		var e event
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			log.Warnf("skip invalid event %s", strings.TrimSpace(line))
+			continue
+		}
+		if !since.IsZero() && e.Time.Before(since) {
+			continue
+		}
+		if !until.IsZero() && e.Time.After(until) {
+			return nil
+		}
+		if !matchEventFilters(&e, filters) {
+			continue
+		}
+		if err := printEvent(&e, format, tmpl); err != nil {
+			return err
+		}
+	}
+}
+
+func printEvent(e *event, format string, tmpl *template.Template) error {
+	switch {
+	case tmpl != nil:
+		if err := tmpl.Execute(os.Stdout, e); err != nil {
+			return fmt.Errorf("execute format error %v", err)
+		}
+		fmt.Println()
+	case format == "json":
+		jsonBytes, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("json marshal error %v", err)
+		}
+		fmt.Println(string(jsonBytes))
+	default:
+		// 与 docker events 一样输出为 "<时间> <类型> <动作> <ID> (key=value, ...)"
+		var keys []string
+		for key := range e.Attributes {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		var attrs []string
+		for _, key := range keys {
+			attrs = append(attrs, key + "=" + e.Attributes[key])
+		}
+		fmt.Printf("%s %s %s %s (%s)\n", e.Time.Format(time.RFC3339Nano), e.Type, e.Action, e.ID, strings.Join(attrs, ", "))
+	}
+	return nil
+}
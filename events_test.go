@@ -0,0 +1,126 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseEventTime(t *testing.T) {
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "2020-05-01T10:00:00Z", want: time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)},
+		{value: "2020-05-01T10:00:00.5+08:00", want: time.Date(2020, 5, 1, 2, 0, 0, 500000000, time.UTC)},
+		{value: "1588334400", want: time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)},
+		{value: "1588334400.25", want: time.Date(2020, 5, 1, 12, 0, 0, 250000000, time.UTC)},
+		{value: "1588334400.000000001", want: time.Date(2020, 5, 1, 12, 0, 0, 1, time.UTC)},
+		{value: "1588334400.", wantErr: true},
+		{value: "1588334400.0000000001", wantErr: true},
+		{value: "1588334400.-5", wantErr: true},
+		{value: "1588334400.+5", wantErr: true},
+		// 时长表示相对当前时间之前
+		{value: "10m", want: now.Add(-10 * time.Minute)},
+		{value: "1h30m", want: now.Add(-90 * time.Minute)},
+		{value: "-5s", want: now.Add(5 * time.Second)},
+		{value: "", wantErr: true},
+		{value: "yesterday", wantErr: true},
+		{value: "10 minutes", wantErr: true},
+		{value: "2020-05-01", wantErr: true},
+		{value: "2020-05-01 10:00:00", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseEventTime(tt.value, now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseEventTime(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !got.Equal(tt.want) {
+			t.Errorf("parseEventTime(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseEventFilters(t *testing.T) {
+	tests := []struct {
+		rawFilters []string
+		want       map[string][]string
+		wantErr    bool
+	}{
+		{want: map[string][]string{}},
+		{
+			rawFilters: []string{"type=container", "event=start", "event=die", "container=web"},
+			want: map[string][]string{
+				"type":      {"container"},
+				"event":     {"start", "die"},
+				"container": {"web"},
+			},
+		},
+		{rawFilters: []string{"image=busybox", "network=testbr"}, want: map[string][]string{"image": {"busybox"}, "network": {"testbr"}}},
+		// 值可以为空或包含 =
+		{rawFilters: []string{"container="}, want: map[string][]string{"container": {""}}},
+		{rawFilters: []string{"image=a=b"}, want: map[string][]string{"image": {"a=b"}}},
+		{rawFilters: []string{"event"}, wantErr: true},
+		{rawFilters: []string{"label=env=prod"}, wantErr: true},
+		{rawFilters: []string{"Type=container"}, wantErr: true},
+		{rawFilters: []string{"=start"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseEventFilters(tt.rawFilters)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseEventFilters(%q) error = %v, wantErr %v", tt.rawFilters, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseEventFilters(%q) = %v, want %v", tt.rawFilters, got, tt.want)
+		}
+	}
+}
+
+func TestMatchEventFilters(t *testing.T) {
+	containerEvent := &event{
+		Type:       eventTypeContainer,
+		Action:     "die",
+		ID:         "123456789012",
+		Attributes: map[string]string{"name": "web", "image": "busybox", "exitCode": "1"},
+	}
+	networkEvent := &event{
+		Type:       eventTypeNetwork,
+		Action:     "connect",
+		ID:         "testbr",
+		Attributes: map[string]string{"container": "123456789012", "name": "web"},
+	}
+	tests := []struct {
+		e       *event
+		filters map[string][]string
+		want    bool
+	}{
+		{e: containerEvent, filters: map[string][]string{}, want: true},
+		{e: containerEvent, filters: map[string][]string{"type": {"container"}}, want: true},
+		{e: containerEvent, filters: map[string][]string{"type": {"network"}}, want: false},
+		{e: containerEvent, filters: map[string][]string{"event": {"start", "die"}}, want: true},
+		{e: containerEvent, filters: map[string][]string{"event": {"start"}}, want: false},
+		{e: containerEvent, filters: map[string][]string{"container": {"web"}}, want: true},
+		{e: containerEvent, filters: map[string][]string{"container": {"123456789012"}}, want: true},
+		{e: containerEvent, filters: map[string][]string{"container": {"db"}}, want: false},
+		{e: containerEvent, filters: map[string][]string{"image": {"busybox"}}, want: true},
+		{e: containerEvent, filters: map[string][]string{"network": {"testbr"}}, want: false},
+		// 网络事件按关联的容器 ID 或容器名匹配
+		{e: networkEvent, filters: map[string][]string{"container": {"123456789012"}}, want: true},
+		{e: networkEvent, filters: map[string][]string{"container": {"web"}}, want: true},
+		{e: networkEvent, filters: map[string][]string{"container": {"testbr"}}, want: false},
+		{e: networkEvent, filters: map[string][]string{"network": {"testbr"}}, want: true},
+		{e: networkEvent, filters: map[string][]string{"image": {"busybox"}}, want: false},
+		// 不同的过滤条件之间为与的关系
+		{e: containerEvent, filters: map[string][]string{"type": {"container"}, "event": {"start"}}, want: false},
+		{e: networkEvent, filters: map[string][]string{"type": {"network"}, "event": {"connect"}, "container": {"web"}}, want: true},
+	}
+	for _, tt := range tests {
+		if got := matchEventFilters(tt.e, tt.filters); got != tt.want {
+			t.Errorf("matchEventFilters(%s %s, %v) = %v, want %v", tt.e.Type, tt.e.Action, tt.filters, got, tt.want)
+		}
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
-	"io/ioutil"
+	"lumper/container"
 	"strings"
 	"os/exec"
 	"os"
@@ -19,7 +19,7 @@ var execCommand = cli.Command{
 	Usage:  "Exec command in container",
 	Action: func(context *cli.Context) error {
 		if os.Getenv(ENV_EXEC_PID) != "" {
-			log.Infof("pidf callback pid %s", os.Getpid())
+			log.Infof("pidf callback pid %d", os.Getpid())
 			return nil
 		}
 		if len(context.Args()) < 2 {
@@ -36,11 +36,20 @@ var execCommand = cli.Command{
 }
 
 func ExecContainer(containerName string, cmdArray []string)  {
-	pid, err := getContainerPidByName(containerName)
+	containerInfo, err := loadContainerInfo(containerName)
 	if err != nil {
-		log.Errorf("get container pid by name %s error %v", containerName, err)
+		log.Errorf("get container %s info error %v", containerName, err)
 		return
 	}
+	if containerInfo.Status == container.PAUSED {
+		log.Errorf("container %s is paused, unpause the container before exec", containerName)
+		return
+	}
+	if containerInfo.Status != container.RUNNING {
+		log.Errorf("container %s is not running", containerName)
+		return
+	}
+	pid := containerInfo.Pid
 	cmdStr := strings.Join(cmdArray, " ")
 	log.Infof("container pid %s", pid)
 	log.Infof("command %s", cmdStr)
@@ -49,24 +58,10 @@ func ExecContainer(containerName string, cmdArray []string)  {
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
-	os.Setenv(ENV_EXEC_PID, pid)
-	os.Setenv(ENV_EXEC_CMD, cmdStr)
-	containerEnvs := getEnvByPid(pid)
-	cmd.Env = append(os.Environ(), containerEnvs...)
+	// 使用容器记录的环境变量，不继承宿主机的环境变量
+	cmd.Env = append(container.ContainerEnv(containerInfo), ENV_EXEC_PID + "=" + pid, ENV_EXEC_CMD + "=" + cmdStr)
 
 	if err := cmd.Run(); err != nil {
 		log.Errorf("exec container %s error %v", containerName, err)
 	}
 }
-
-func getEnvByPid(pid string) []string {
-	path := fmt.Sprintf("/proc/%s/environ", pid)
-	contentBytes, err := ioutil.ReadFile(path)
-	if err != nil {
-		log.Errorf("read file %s error %v", path, err)
-		return nil
-	}
-	// 多个环境变量中的分隔符是 \u0000
-	envs := strings.Split(string(contentBytes), "\u0000")
-	return envs
-}
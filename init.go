@@ -12,7 +12,13 @@ var initCommand = cli.Command{
 	Usage:  "Init container process",
 	Action: func(context *cli.Context) error {
 		log.Infof("initing")
-		err := container.RunContainerInitProcess()
-		return err
+		// 启动失败时以 InitError 中的退出码退出，父进程据此记录容器退出码
+		if err := container.RunContainerInitProcess(); err != nil {
+			if initErr, ok := err.(*container.InitError); ok {
+				return cli.NewExitError(initErr.Error(), initErr.ExitCode)
+			}
+			return err
+		}
+		return nil
 	},
 }
\ No newline at end of file
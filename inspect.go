@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/urfave/cli"
+	"lumper/cgroups"
+	"lumper/container"
+	"lumper/network"
+	"os"
+	"text/template"
+)
+
+var inspectCommand = cli.Command{
+	Name:   "inspect",
+	Usage:  "Display detailed information of a container or network",
+	Flags:  []cli.Flag{
+		cli.StringFlag{
+			Name:  "format, f",
+			Usage: "format the output using the given Go template",
+		},
+		cli.StringFlag{
+			Name:  "type",
+			Usage: "only inspect objects of the given type, container or network",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("missing container or network name")
+		}
+		objType := context.String("type")
+		if objType != "" && objType != "container" && objType != "network" {
+			return fmt.Errorf("invalid type %s", objType)
+		}
+		var tmpl *template.Template
+		if format := context.String("format"); format != "" {
+			var err error
+			tmpl, err = template.New("inspect").Funcs(templateFuncs).Parse(format)
+			if err != nil {
+				return fmt.Errorf("parse format error %v", err)
+			}
+		}
+		for _, name := range context.Args() {
+			obj, err := inspectObject(name, objType)
+			if err != nil {
+				return err
+			}
+			if err := printInspect(obj, tmpl); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// 容器的详细信息
+type containerInspect struct {
+	*container.ContainerInfo
+	LogPath     string                 `json:"logPath"`     // 容器日志文件
+	CgroupPaths map[string]string      `json:"cgroupPaths"` // 各个 Subsystem 中的 Cgroup 路径
+	WorkSpace   container.WorkSpace    `json:"workSpace"`   // overlay2 各层目录
+	Mounts      []mountInspect         `json:"mounts"`      // 数据卷挂载
+	Endpoint    *network.EndpointInfo  `json:"endpoint"`    // 网络端点
+}
+
+// 数据卷挂载信息
+type mountInspect struct {
+	Source      string `json:"source"`      // 宿主机目录
+	Destination string `json:"destination"` // 容器内目录
+}
+
+// 网络的详细信息
+type networkInspect struct {
+	Name       string            `json:"name"`       // 网络名
+	Driver     string            `json:"driver"`     // 网络驱动名
+	Subnet     string            `json:"subnet"`     // 网段
+	Gateway    string            `json:"gateway"`    // 网关地址
+	Containers map[string]string `json:"containers"` // 连接到网络的容器名和 IP 地址
+}
+
+// 模板中可以使用的函数
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// 按名字查找容器或网络，优先查找容器
+func inspectObject(name, objType string) (interface{}, error) {
+	if objType != "network" {
+		configFilePath := fmt.Sprintf(container.DefaultInfoLocation, name) + container.ConfigName
+		if exist, _ := container.PathExists(configFilePath); exist {
+			containerInfo, err := loadContainerInfo(name)
+			if err != nil {
+				return nil, err
+			}
+			return getContainerInspect(containerInfo), nil
+		}
+	}
+	if objType != "container" {
+		network.Init()
+		if nw, ok := network.GetNetwork(name); ok {
+			return getNetworkInspect(nw), nil
+		}
+	}
+	return nil, fmt.Errorf("no such object %s", name)
+}
+
+func getContainerInspect(containerInfo *container.ContainerInfo) *containerInspect {
+	dirUrl := fmt.Sprintf(container.DefaultInfoLocation, containerInfo.Name)
+	info := &containerInspect{
+		ContainerInfo: containerInfo,
+		LogPath:       dirUrl + container.ContainerLogFile,
+		CgroupPaths:   cgroups.NewCgroupManager(containerInfo.GetCgroupPath()).Paths(),
+		WorkSpace:     container.GetWorkSpace(containerInfo.Name, containerInfo.Image),
+		Mounts:        []mountInspect{},
+	}
+	if volumeUrls := container.ParseVolume(containerInfo.Volume); volumeUrls != nil {
+		info.Mounts = append(info.Mounts, mountInspect{
+			Source:      volumeUrls[0],
+			Destination: volumeUrls[1],
+		})
+	}
+	if containerInfo.Network != "" {
+		network.Init()
+		if endpoint, err := network.GetEndpointInfo(containerInfo); err == nil {
+			info.Endpoint = endpoint
+		}
+	}
+	return info
+}
+
+func getNetworkInspect(nw *network.Network) *networkInspect {
+	info := &networkInspect{
+		Name:       nw.Name,
+		Driver:     nw.Driver,
+		Gateway:    nw.IPRange.IP.String(),
+		Containers: map[string]string{},
+	}
+	if subnet := getNetworkSubnet(nw); subnet != "" {
+		info.Subnet = subnet
+	}
+	containers, _ := getAllContainerInfo()
+	for _, containerInfo := range containers {
+		if containerInfo.Network == nw.Name && containerInfo.IPAddress != "" {
+			info.Containers[containerInfo.Name] = containerInfo.IPAddress
+		}
+	}
+	return info
+}
+
+// 网络配置中保存的是网关地址，转换成网段
+func getNetworkSubnet(nw *network.Network) string {
+	if nw.IPRange == nil {
+		return ""
+	}
+	subnet := *nw.IPRange
+	subnet.IP = nw.IPRange.IP.Mask(nw.IPRange.Mask)
+	return subnet.String()
+}
+
+// 输出详细信息，没有指定模板时以 json 格式输出
+func printInspect(obj interface{}, tmpl *template.Template) error {
+	if tmpl != nil {
+		if err := tmpl.Execute(os.Stdout, obj); err != nil {
+			return fmt.Errorf("execute format error %v", err)
+		}
+		fmt.Println()
+		return nil
+	}
+	jsonBytes, err := json.MarshalIndent(obj, "", "    ")
+	if err != nil {
+		return fmt.Errorf("json marshal error %v", err)
+	}
+	fmt.Println(string(jsonBytes))
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	"golang.org/x/sys/unix"
+	"lumper/container"
+	"strconv"
+	"strings"
+)
+
+var killCommand = cli.Command{
+	Name:   "kill",
+	Usage:  "Send a signal to a container",
+	Flags:  []cli.Flag{
+		cli.StringFlag{
+			Name:  "signal, s",
+			Value: "SIGKILL",
+			Usage: "signal to send to the container, name or number",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("missing container name")
+		}
+		sig, err := parseSignal(context.String("signal"))
+		if err != nil {
+			return err
+		}
+		containerName := context.Args().Get(0)
+		if err := killContainer(containerName, sig); err != nil {
+			log.Errorf("kill container %s error %v", containerName, err)
+		}
+		return nil
+	},
+}
+
+// 容器主进程已经退出
+var errContainerNotRunning = errors.New("container is not running")
+
+// 向容器主进程发送信号，容器状态由监控进程在进程退出后记录
+func killContainer(containerName string, sig unix.Signal) error {
+	// 持有容器锁时监控进程无法记录退出，确认主进程仍然存活后再发送信号，避免发给被复用的 PID
+	unlock, err := lockContainer(containerName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	containerInfo, err := loadContainerInfoLocked(containerName)
+	if err != nil {
+		return err
+	}
+	// 暂停的容器收到的信号在解冻后才会处理
+	if (containerInfo.Status != container.RUNNING && containerInfo.Status != container.PAUSED) || !isContainerProcessAlive(containerInfo) {
+		return errContainerNotRunning
+	}
+	pid, err := strconv.Atoi(containerInfo.Pid)
+	if err != nil {
+		return fmt.Errorf("convert pid from string to int error %v", err)
+	}
+	if err := unix.Kill(pid, sig); err != nil {
+		return fmt.Errorf("send signal %s error %v", unix.SignalName(sig), err)
+	}
+	logContainerEvent("kill", containerInfo, map[string]string{"signal": strconv.Itoa(int(sig))})
+	return nil
+}
+
+// 解析信号，支持 SIGHUP、HUP 和 1 等形式
+func parseSignal(rawSignal string) (unix.Signal, error) {
+	if num, err := strconv.Atoi(rawSignal); err == nil {
+		if num <= 0 || unix.SignalName(unix.Signal(num)) == "" {
+			return 0, fmt.Errorf("invalid signal %s", rawSignal)
+		}
+		return unix.Signal(num), nil
+	}
+	name := strings.ToUpper(rawSignal)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	sig := unix.SignalNum(name)
+	if sig == 0 {
+		return 0, fmt.Errorf("invalid signal %s", rawSignal)
+	}
+	return sig, nil
+}
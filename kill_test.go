@@ -0,0 +1,42 @@
+package main
+
+import (
+	"golang.org/x/sys/unix"
+	"testing"
+)
+
+func TestParseSignal(t *testing.T) {
+	tests := []struct {
+		rawSignal string
+		want      unix.Signal
+		wantErr   bool
+	}{
+		{rawSignal: "SIGKILL", want: unix.SIGKILL},
+		{rawSignal: "KILL", want: unix.SIGKILL},
+		{rawSignal: "sigterm", want: unix.SIGTERM},
+		{rawSignal: "hup", want: unix.SIGHUP},
+		{rawSignal: "SigUsr1", want: unix.SIGUSR1},
+		{rawSignal: "9", want: unix.SIGKILL},
+		{rawSignal: "15", want: unix.SIGTERM},
+		{rawSignal: "31", want: unix.SIGSYS},
+		{rawSignal: "", wantErr: true},
+		{rawSignal: "SIG", wantErr: true},
+		{rawSignal: "SIGFOO", wantErr: true},
+		{rawSignal: "SIGSIGKILL", wantErr: true},
+		{rawSignal: "0", wantErr: true},
+		{rawSignal: "-9", wantErr: true},
+		{rawSignal: "65", wantErr: true},
+		{rawSignal: "9a", wantErr: true},
+		{rawSignal: " KILL", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseSignal(tt.rawSignal)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSignal(%q) error = %v, wantErr %v", tt.rawSignal, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("parseSignal(%q) = %v, want %v", tt.rawSignal, got, tt.want)
+		}
+	}
+}
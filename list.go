@@ -7,55 +7,284 @@ import (
 	"github.com/urfave/cli"
 	"io/ioutil"
 	"os"
+	"strings"
 	"text/tabwriter"
+	"text/template"
+	"time"
 	"lumper/container"
 )
 
 var listCommand = cli.Command{
 	Name:   "list",
-	Aliases: []string{"ls"},
-	Usage:  "List all the containers",
+	Aliases: []string{"ls", "ps"},
+	Usage:  "List containers",
+	Flags:  []cli.Flag{
+		cli.BoolFlag{
+			Name:  "all, a",
+			Usage: "show all containers, default only running",
+		},
+		cli.BoolFlag{
+			Name:  "quiet, q",
+			Usage: "only display container IDs",
+		},
+		cli.StringSliceFlag{
+			Name:  "filter",
+			Usage: "filter output, status=|name=|network=|label=|ancestor=",
+		},
+		cli.StringFlag{
+			Name:  "format",
+			Value: "table",
+			Usage: "output format, table|json|Go template",
+		},
+	},
 	Action: func(context *cli.Context) error {
-		ListContainers()
-		return nil
+		filters, err := parseListFilters(context.StringSlice("filter"))
+		if err != nil {
+			return err
+		}
+		format := context.String("format")
+		if context.Bool("quiet") {
+			format = "{{.ID}}"
+		}
+		return ListContainers(context.Bool("all"), filters, format)
 	},
 }
 
-func ListContainers()  {
-	dirUrl := fmt.Sprintf(container.DefaultInfoLocation, "")
-	dirUrl = dirUrl[:len(dirUrl)-1]
-	files, err := ioutil.ReadDir(dirUrl)
+// 容器列表中展示的信息
+type containerSummary struct {
+	ID         string            `json:"id"`         // 容器 ID
+	Name       string            `json:"name"`       // 容器名
+	Pid        string            `json:"pid"`        // init 进程 PID
+	Image      string            `json:"image"`      // 镜像名
+	Command    string            `json:"command"`    // 运行命令
+	CreatedAt  string            `json:"createdAt"`  // 创建时间
+	RunningFor string            `json:"runningFor"` // 创建至今的时间
+	State      string            `json:"state"`      // 容器状态
+	Status     string            `json:"status"`     // 可读的状态描述
+	Network    string            `json:"network"`    // 网络名
+	IPAddress  string            `json:"ipAddress"`  // IP 地址
+	Ports      string            `json:"ports"`      // 端口映射
+	Labels     map[string]string `json:"labels"`     // 标签
+}
+
+func ListContainers(all bool, filters map[string][]string, format string) error {
+	containers, err := getAllContainerInfo()
 	if err != nil {
-		log.Errorf("read dir %s error %v", dirUrl, err)
-		return
+		return err
 	}
-	var containers []*container.ContainerInfo
-	for _, file := range files {
-		// 根据容器配置文件获取信息，并转换成容器信息对象
-		tmpContainer, err := getContainerInfo(file)
-		if err != nil {
-			log.Errorf("get container info error %v", err)
+	var summaries []*containerSummary
+	for _, item := range containers {
+		reconcileContainer(item)
+		// 默认只显示运行中的容器
+		if !all && item.Status != container.RUNNING && item.Status != container.PAUSED && item.Status != container.RESTARTING {
 			continue
 		}
-		containers = append(containers, tmpContainer)
+		if !matchListFilters(item, filters) {
+			continue
+		}
+		summaries = append(summaries, getContainerSummary(item))
+	}
+
+	switch format {
+	case "table", "":
+		return printContainerTable(summaries)
+	case "json":
+		for _, summary := range summaries {
+			jsonBytes, err := json.Marshal(summary)
+			if err != nil {
+				return fmt.Errorf("json marshal error %v", err)
+			}
+			fmt.Println(string(jsonBytes))
+		}
+		return nil
 	}
+	tmpl, err := template.New("list").Funcs(templateFuncs).Parse(format)
+	if err != nil {
+		return fmt.Errorf("parse format error %v", err)
+	}
+	for _, summary := range summaries {
+		if err := tmpl.Execute(os.Stdout, summary); err != nil {
+			return fmt.Errorf("execute format error %v", err)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func printContainerTable(summaries []*containerSummary) error {
 	// 使用 tabwriter 打印容器信息
 	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
-	fmt.Fprint(w, "ID\tNAME\tPID\tSTATUS\tCOMMAND\tCREATED\n")
-	for _, item := range containers {
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
-			item.Id,
+	fmt.Fprint(w, "ID\tNAME\tPID\tIMAGE\tSTATUS\tCOMMAND\tCREATED\tPORTS\n")
+	for _, item := range summaries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			item.ID,
 			item.Name,
 			item.Pid,
+			item.Image,
 			item.Status,
 			item.Command,
-			item.CreatedTime)
+			item.RunningFor,
+			item.Ports)
 	}
 	// 刷新标准输出流缓存区，将容器列表打印出来
 	if err := w.Flush(); err != nil {
 		log.Errorf("flush container info error %v", err)
-		return
+		return err
+	}
+	return nil
+}
+
+func getContainerSummary(item *container.ContainerInfo) *containerSummary {
+	summary := &containerSummary{
+		ID:        item.Id,
+		Name:      item.Name,
+		Pid:       item.Pid,
+		Image:     item.Image,
+		Command:   item.Command,
+		CreatedAt: item.CreatedTime,
+		State:     item.Status,
+		Status:    containerStatusText(item),
+		Network:   item.Network,
+		IPAddress: item.IPAddress,
+		Ports:     strings.Join(item.PortMapping, ","),
+		Labels:    item.Labels,
+	}
+	if createdAt, err := time.ParseInLocation(container.CreatedTimeLayout, item.CreatedTime, time.Local); err == nil {
+		summary.RunningFor = humanDuration(time.Since(createdAt)) + " ago"
+	}
+	return summary
+}
+
+// 根据容器状态和时间生成可读的状态描述，如 Up 3 minutes、Exited (1) 2 hours ago
+func containerStatusText(item *container.ContainerInfo) string {
+	switch item.Status {
+	case container.RUNNING:
+		return "Up " + humanDuration(time.Since(item.StartedAt))
+	case container.PAUSED:
+		return "Up " + humanDuration(time.Since(item.StartedAt)) + " (Paused)"
+	case container.RESTARTING:
+		return fmt.Sprintf("Restarting (%d) %s ago", item.ExitCode, humanDuration(time.Since(item.FinishedAt)))
+	case container.EXIT, container.STOP:
+		if item.FinishedAt.IsZero() {
+			return statusTitle(item.Status)
+		}
+		return fmt.Sprintf("%s (%d) %s ago", statusTitle(item.Status), item.ExitCode, humanDuration(time.Since(item.FinishedAt)))
+	}
+	return statusTitle(item.Status)
+}
+
+// 首字母大写的容器状态，用于状态描述
+var statusTitles = map[string]string{
+	container.CREATED:    "Created",
+	container.RUNNING:    "Running",
+	container.RESTARTING: "Restarting",
+	container.PAUSED:     "Paused",
+	container.STOP:       "Stopped",
+	container.EXIT:       "Exited",
+}
+
+func statusTitle(status string) string {
+	if title, ok := statusTitles[status]; ok {
+		return title
+	}
+	return status
+}
+
+// 将时间间隔转换成可读的描述
+func humanDuration(d time.Duration) string {
+	if seconds := int(d.Seconds()); seconds < 1 {
+		return "Less than a second"
+	} else if seconds == 1 {
+		return "1 second"
+	} else if seconds < 60 {
+		return fmt.Sprintf("%d seconds", seconds)
+	} else if minutes := int(d.Minutes()); minutes == 1 {
+		return "About a minute"
+	} else if minutes < 60 {
+		return fmt.Sprintf("%d minutes", minutes)
+	} else if hours := int(d.Hours() + 0.5); hours == 1 {
+		return "About an hour"
+	} else if hours < 48 {
+		return fmt.Sprintf("%d hours", hours)
+	} else if hours < 24*7*2 {
+		return fmt.Sprintf("%d days", hours/24)
+	} else if hours < 24*30*2 {
+		return fmt.Sprintf("%d weeks", hours/24/7)
+	} else if hours < 24*365*2 {
+		return fmt.Sprintf("%d months", hours/24/30)
+	}
+	return fmt.Sprintf("%d years", int(d.Hours())/24/365)
+}
+
+// 解析过滤条件，同一个键的多个值之间为或关系，不同键之间为与关系
+func parseListFilters(rawFilters []string) (map[string][]string, error) {
+	filters := map[string][]string{}
+	for _, rawFilter := range rawFilters {
+		kv := strings.SplitN(rawFilter, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return nil, fmt.Errorf("bad format of filter %s, expected key=value", rawFilter)
+		}
+		switch kv[0] {
+		case "status", "name", "network", "label", "ancestor":
+			filters[kv[0]] = append(filters[kv[0]], kv[1])
+		default:
+			return nil, fmt.Errorf("invalid filter %s", kv[0])
+		}
+	}
+	return filters, nil
+}
+
+// 判断容器是否满足所有过滤条件
+func matchListFilters(item *container.ContainerInfo, filters map[string][]string) bool {
+	for key, values := range filters {
+		matched := false
+		for _, value := range values {
+			switch key {
+			case "status":
+				matched = item.Status == value
+			case "name":
+				matched = strings.Contains(item.Name, value)
+			case "network":
+				matched = item.Network == value
+			case "ancestor":
+				matched = item.Image == value
+			case "label":
+				// label 过滤支持 key 和 key=value 两种形式
+				kv := strings.SplitN(value, "=", 2)
+				labelValue, ok := item.Labels[kv[0]]
+				matched = ok && (len(kv) == 1 || labelValue == kv[1])
+			}
+			if matched {
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// 获取所有容器的信息
+func getAllContainerInfo() ([]*container.ContainerInfo, error) {
+	dirUrl := fmt.Sprintf(container.DefaultInfoLocation, "")
+	dirUrl = dirUrl[:len(dirUrl)-1]
+	files, err := ioutil.ReadDir(dirUrl)
+	if err != nil {
+		log.Errorf("read dir %s error %v", dirUrl, err)
+		return nil, err
+	}
+	var containers []*container.ContainerInfo
+	for _, file := range files {
+		// 根据容器配置文件获取信息，并转换成容器信息对象
+		tmpContainer, err := getContainerInfo(file)
+		if err != nil {
+			log.Errorf("get container info error %v", err)
+			continue
+		}
+		containers = append(containers, tmpContainer)
 	}
+	return containers, nil
 }
 
 func getContainerInfo(file os.FileInfo) (*container.ContainerInfo, error) {
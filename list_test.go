@@ -0,0 +1,101 @@
+package main
+
+import (
+	"lumper/container"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseListFilters(t *testing.T) {
+	tests := []struct {
+		rawFilters []string
+		want       map[string][]string
+		wantErr    bool
+	}{
+		{want: map[string][]string{}},
+		{
+			rawFilters: []string{"status=running", "status=paused", "label=env=prod", "name=web"},
+			want: map[string][]string{
+				"status": {"running", "paused"},
+				"label":  {"env=prod"},
+				"name":   {"web"},
+			},
+		},
+		{rawFilters: []string{"network=testbr", "ancestor=busybox"}, want: map[string][]string{"network": {"testbr"}, "ancestor": {"busybox"}}},
+		{rawFilters: []string{"status"}, wantErr: true},
+		{rawFilters: []string{"status="}, wantErr: true},
+		{rawFilters: []string{"=running"}, wantErr: true},
+		{rawFilters: []string{"id=abc"}, wantErr: true},
+		{rawFilters: []string{"Status=running"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseListFilters(tt.rawFilters)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseListFilters(%q) error = %v, wantErr %v", tt.rawFilters, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseListFilters(%q) = %v, want %v", tt.rawFilters, got, tt.want)
+		}
+	}
+}
+
+func TestMatchListFilters(t *testing.T) {
+	item := &container.ContainerInfo{
+		Name:    "web-1",
+		Status:  container.RUNNING,
+		Network: "testbr",
+		Image:   "busybox",
+		Labels:  map[string]string{"env": "prod", "tier": ""},
+	}
+	tests := []struct {
+		filters map[string][]string
+		want    bool
+	}{
+		{filters: map[string][]string{}, want: true},
+		{filters: map[string][]string{"status": {"running"}}, want: true},
+		{filters: map[string][]string{"status": {"exited", "running"}}, want: true},
+		{filters: map[string][]string{"status": {"exited"}}, want: false},
+		{filters: map[string][]string{"name": {"web"}}, want: true},
+		{filters: map[string][]string{"name": {"db"}}, want: false},
+		{filters: map[string][]string{"network": {"testbr"}}, want: true},
+		{filters: map[string][]string{"network": {"test"}}, want: false},
+		{filters: map[string][]string{"ancestor": {"busybox"}}, want: true},
+		{filters: map[string][]string{"label": {"env"}}, want: true},
+		{filters: map[string][]string{"label": {"env=prod"}}, want: true},
+		{filters: map[string][]string{"label": {"env=dev"}}, want: false},
+		{filters: map[string][]string{"label": {"tier="}}, want: true},
+		{filters: map[string][]string{"label": {"owner"}}, want: false},
+		// 不同的过滤条件之间为与的关系
+		{filters: map[string][]string{"status": {"running"}, "label": {"env=dev"}}, want: false},
+		{filters: map[string][]string{"status": {"running"}, "name": {"web"}, "label": {"env=prod"}}, want: true},
+	}
+	for _, tt := range tests {
+		if got := matchListFilters(item, tt.filters); got != tt.want {
+			t.Errorf("matchListFilters(%v) = %v, want %v", tt.filters, got, tt.want)
+		}
+	}
+}
+
+func TestContainerStatusText(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		item *container.ContainerInfo
+		want string
+	}{
+		{item: &container.ContainerInfo{Status: container.CREATED}, want: "Created"},
+		{item: &container.ContainerInfo{Status: container.RUNNING, StartedAt: now}, want: "Up Less than a second"},
+		{item: &container.ContainerInfo{Status: container.PAUSED, StartedAt: now}, want: "Up Less than a second (Paused)"},
+		{item: &container.ContainerInfo{Status: container.EXIT}, want: "Exited"},
+		{item: &container.ContainerInfo{Status: container.EXIT, ExitCode: 137, FinishedAt: now}, want: "Exited (137) Less than a second ago"},
+		{item: &container.ContainerInfo{Status: container.STOP, FinishedAt: now}, want: "Stopped (0) Less than a second ago"},
+		{item: &container.ContainerInfo{Status: container.RESTARTING, ExitCode: 1, FinishedAt: now}, want: "Restarting (1) Less than a second ago"},
+		{item: &container.ContainerInfo{Status: "unknown"}, want: "unknown"},
+	}
+	for _, tt := range tests {
+		if got := containerStatusText(tt.item); got != tt.want {
+			t.Errorf("containerStatusText(%s) = %q, want %q", tt.item.Status, got, tt.want)
+		}
+	}
+}
@@ -17,14 +17,25 @@ func main()  {
 
 	app.Commands = []cli.Command{
 		initCommand,
+		shimCommand,
 		runCommand,
+		startCommand,
 		listCommand,
 		stopCommand,
+		pauseCommand,
+		unpauseCommand,
+		killCommand,
+		waitCommand,
 		removeCommand,
 		logCommand,
 		execCommand,
 		commitCommand,
+		inspectCommand,
+		statsCommand,
+		topCommand,
+		eventsCommand,
 		networkCommand,
+		systemCommand,
 	}
 
 	app.Before = func(context *cli.Context) error {
@@ -72,7 +72,16 @@ func (d *BridgeNetworkDriver) Connect(network *Network, endpoint *Endpoint) erro
 	return nil
 }
 
+// 断开网络和网络端点
 func (d *BridgeNetworkDriver) Disconnect(network Network, endpoint *Endpoint) error {
+	// 容器 Net Namespace 销毁时 Veth 会被一并删除，存在时才需要手动删除
+	link, err := netlink.LinkByName(endpoint.ID[:5])
+	if err != nil {
+		return nil
+	}
+	if err := netlink.LinkDel(link); err != nil {
+		return fmt.Errorf("delete endpoint device error %v", err)
+	}
 	return nil
 }
 
@@ -123,7 +132,7 @@ func setInterfaceIP(name, rawIP string) error {
 	if err != nil {
 		return err
 	}
-	addr := &netlink.Addr{ipNet, "", 0, 0, nil, nil, 0, 0}
+	addr := &netlink.Addr{IPNet: ipNet}
 	return netlink.AddrAdd(iface, addr)
 }
 
@@ -2,6 +2,7 @@ package network
 
 import (
 	"encoding/json"
+	"fmt"
 	log "github.com/sirupsen/logrus"
 	"net"
 	"os"
@@ -149,4 +150,88 @@ func (ipam *IPAM) Release(subnet *net.IPNet, ipaddr *net.IP) error {
 	// 保存释放掉 IP 后的网段 IP 分配信息
 	ipam.dump()
 	return nil
+}
+
+// 在网段中分配指定的 IP 地址，地址不在网段内时返回错误
+func (ipam *IPAM) AllocateIP(subnet *net.IPNet, ipaddr net.IP) error {
+	ipam.Subnets = &map[string]string{}
+	// 加载已分配的网段信息
+	if err := ipam.load(); err != nil {
+		log.Errorf("load allocation info error %v", err)
+	}
+
+	_, subnet, _ = net.ParseCIDR(subnet.String())
+	allocIP := ipaddr.To4()
+	if allocIP == nil || !subnet.Contains(allocIP) {
+		return fmt.Errorf("ip %s not in subnet %s", ipaddr, subnet)
+	}
+
+	one, size := subnet.Mask.Size()
+	if _, exist := (*ipam.Subnets)[subnet.String()]; !exist {
+		(*ipam.Subnets)[subnet.String()] = strings.Repeat("0", 1 << uint8(size - one))
+	}
+
+	// 计算 IP 地址在网段位图数组中的索引位置，IP 从 1 开始分配，所以减 1
+	c := -1
+	subnetIP := subnet.IP.To4()
+	for t := uint(4); t > 0; t -= 1 {
+		c += int(allocIP[t - 1] - subnetIP[t - 1]) << ((4 - t) * 8)
+	}
+	if c < 0 || c >= len((*ipam.Subnets)[subnet.String()]) {
+		return fmt.Errorf("ip %s out of range of subnet %s", ipaddr, subnet)
+	}
+
+	ipalloc := []byte((*ipam.Subnets)[subnet.String()])
+	// 停止的容器释放的 IP 可能已经分配给了其他容器
+	if ipalloc[c] == '1' {
+		return fmt.Errorf("ip %s already allocated in subnet %s", ipaddr, subnet)
+	}
+	ipalloc[c] = '1'
+	(*ipam.Subnets)[subnet.String()] = string(ipalloc)
+	return ipam.dump()
+}
+
+// 释放不在使用中的 IP，删除不属于任何网络的网段，busy 中的网段有容器正在启动，不做处理
+func (ipam *IPAM) prune(subnets, busy, inUse map[string]bool, dryRun bool) ([]string, error) {
+	ipam.Subnets = &map[string]string{}
+	if err := ipam.load(); err != nil {
+		return nil, err
+	}
+	var pruned []string
+	for subnetStr, bitmap := range *ipam.Subnets {
+		if !subnets[subnetStr] {
+			pruned = append(pruned, subnetStr)
+			delete(*ipam.Subnets, subnetStr)
+			continue
+		}
+		if busy[subnetStr] {
+			continue
+		}
+		_, subnet, err := net.ParseCIDR(subnetStr)
+		if err != nil {
+			continue
+		}
+		ipalloc := []byte(bitmap)
+		for c := range ipalloc {
+			if ipalloc[c] != '1' {
+				continue
+			}
+			// 由位图索引计算 IP，IP 从 1 开始分配
+			ip := make(net.IP, 4)
+			copy(ip, subnet.IP.To4())
+			for t := uint(4); t > 0; t -= 1 {
+				ip[4 - t] += uint8(c >> ((t - 1) * 8))
+			}
+			ip[3] += 1
+			if !inUse[ip.String()] {
+				pruned = append(pruned, ip.String())
+				ipalloc[c] = '0'
+			}
+		}
+		(*ipam.Subnets)[subnetStr] = string(ipalloc)
+	}
+	if dryRun || len(pruned) == 0 {
+		return pruned, nil
+	}
+	return pruned, ipam.dump()
 }
\ No newline at end of file
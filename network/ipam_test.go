@@ -0,0 +1,88 @@
+package network
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// 生成 /24 网段的位图，indexes 中的位置为已分配
+func bitmap(indexes ...int) string {
+	ipalloc := []byte(strings.Repeat("0", 256))
+	for _, c := range indexes {
+		ipalloc[c] = '1'
+	}
+	return string(ipalloc)
+}
+
+func TestIPAMPrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lumper-ipam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ipam := &IPAM{SubnetAllocatorPath: path.Join(dir, "subnet.json")}
+	allocations := map[string]string{
+		// 网关 .1、使用中的 .2 和已经不用的 .3
+		"192.168.50.0/24": bitmap(0, 1, 2),
+		// 有容器正在启动的网段
+		"192.168.60.0/24": bitmap(0, 5),
+		// 已删除网络的网段
+		"10.9.0.0/24":     bitmap(0, 1),
+	}
+	content, err := json.Marshal(allocations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(ipam.SubnetAllocatorPath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	subnets := map[string]bool{"192.168.50.0/24": true, "192.168.60.0/24": true}
+	busy := map[string]bool{"192.168.60.0/24": true}
+	inUse := map[string]bool{"192.168.50.1": true, "192.168.50.2": true, "192.168.60.1": true}
+	want := []string{"10.9.0.0/24", "192.168.50.3"}
+
+	// dry run 只返回结果，不修改分配文件
+	pruned, err := ipam.prune(subnets, busy, inUse, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(pruned)
+	if !reflect.DeepEqual(pruned, want) {
+		t.Errorf("prune() dry run = %q, want %q", pruned, want)
+	}
+	if after, _ := ioutil.ReadFile(ipam.SubnetAllocatorPath); string(after) != string(content) {
+		t.Errorf("prune() dry run modified allocations: %s", after)
+	}
+
+	pruned, err = ipam.prune(subnets, busy, inUse, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(pruned)
+	if !reflect.DeepEqual(pruned, want) {
+		t.Errorf("prune() = %q, want %q", pruned, want)
+	}
+	ipam.Subnets = &map[string]string{}
+	if err := ipam.load(); err != nil {
+		t.Fatal(err)
+	}
+	wantAllocations := map[string]string{
+		"192.168.50.0/24": bitmap(0, 1),
+		"192.168.60.0/24": bitmap(0, 5),
+	}
+	if !reflect.DeepEqual(*ipam.Subnets, wantAllocations) {
+		t.Errorf("allocations after prune = %v, want %v", *ipam.Subnets, wantAllocations)
+	}
+
+	// 没有可清理的地址时不写入文件
+	pruned, err = ipam.prune(subnets, busy, inUse, false)
+	if err != nil || len(pruned) != 0 {
+		t.Errorf("second prune() = %q, %v, want nothing", pruned, err)
+	}
+}
@@ -40,6 +40,17 @@ type Endpoint struct {
 	Network *Network // 网络
 }
 
+// 容器网络端点的描述信息
+type EndpointInfo struct {
+	ID         string `json:"id"`         // 端点 ID
+	Network    string `json:"network"`    // 网络名
+	Device     string `json:"device"`     // 宿主机上的 Veth 端点
+	PeerDevice string `json:"peerDevice"` // 容器内的 Veth 端点
+	IPAddress  string `json:"ipAddress"`  // 容器 IP 地址
+	Gateway    string `json:"gateway"`    // 网关地址
+	Subnet     string `json:"subnet"`     // 网段
+}
+
 type NetworkDriver interface {
 	// 驱动名
 	Name() string
@@ -104,7 +115,7 @@ func (nw *Network) load(dumpPath string) error {
 
 	err = json.Unmarshal(nwJson[:n], nw)
 	if err != nil {
-		log.Errorf("load network info error", err)
+		log.Errorf("load network info error %v", err)
 		return err
 	}
 	return nil
@@ -158,6 +169,36 @@ func Init() error {
 	return nil
 }
 
+// 获取网络配置
+func GetNetwork(networkName string) (*Network, bool) {
+	nw, ok := networks[networkName]
+	return nw, ok
+}
+
+// 网络端点 ID 由容器 ID 和网络名组成
+func endpointID(cinfo *container.ContainerInfo, networkName string) string {
+	return fmt.Sprintf("%s-%s", cinfo.Id, networkName)
+}
+
+// 获取容器的网络端点信息
+func GetEndpointInfo(cinfo *container.ContainerInfo) (*EndpointInfo, error) {
+	nw, ok := networks[cinfo.Network]
+	if !ok {
+		return nil, fmt.Errorf("no such network %s", cinfo.Network)
+	}
+	id := endpointID(cinfo, cinfo.Network)
+	_, subnet, _ := net.ParseCIDR(nw.IPRange.String())
+	return &EndpointInfo{
+		ID:         id,
+		Network:    nw.Name,
+		Device:     id[:5],
+		PeerDevice: "cif-" + id[:5],
+		IPAddress:  cinfo.IPAddress,
+		Gateway:    nw.IPRange.IP.String(),
+		Subnet:     subnet.String(),
+	}, nil
+}
+
 // 创建网络
 func CreateNetwork(driver, subnet, name string) error {
 	// 将网段字符串转换成 net.IPNet 对象
@@ -203,25 +244,6 @@ func DeleteNetwork(networkName string) error {
 	return nw.remove(defaultNetworkPath)
 }
 
-// 释放容器 IP 和移除端口映射
-func ReleaseContainerNetwork(cinfo *container.ContainerInfo) error {
-	nw, ok := networks[cinfo.Network]
-	if !ok {
-		return fmt.Errorf("no such network %s", cinfo.Network)
-	}
-	// 释放 IP
-	ip := net.ParseIP(cinfo.IPAddress)
-	if err := ipAllocator.Release(nw.IPRange, &ip); err != nil {
-		return fmt.Errorf("remove network ip errorr %v", err)
-	}
-	// 移除端口映射
-	if err := removePortMapping(cinfo); err != nil {
-		log.Errorf("remove portmapping error %v", err)
-		return err
-	}
-	return nil
-}
-
 // 进入容器的 Net Namespace
 func enterContainerNetns(enLink *netlink.Link, cinfo *container.ContainerInfo) func() {
 	f, err := os.OpenFile(fmt.Sprintf("/proc/%s/ns/net", cinfo.Pid), os.O_RDONLY, 0)
@@ -331,23 +353,34 @@ func Connect(networkName string, cinfo *container.ContainerInfo) error {
 		return fmt.Errorf("no such network %s", networkName)
 	}
 
-	// 从 IP 段中分配容器 IP 地址
-	ip, err := ipAllocator.Allocate(network.IPRange)
-	if err != nil {
-		return err
+	// 重新启动的容器尽量沿用之前的 IP 地址，已被其他容器占用时从 IP 段中分配
+	var err error
+	ip := net.ParseIP(cinfo.IPAddress)
+	if ip == nil || ipAllocator.AllocateIP(network.IPRange, ip) != nil {
+		ip, err = ipAllocator.Allocate(network.IPRange)
+		if err != nil {
+			return err
+		}
 	}
+	// 保存容器 IP 地址，分配 IP 后即视为已连接，之后的步骤失败时断开网络释放已经创建的资源
+	cinfo.IPAddress = ip.String()
+	cinfo.NetworkConnected = true
+	defer func() {
+		if err != nil {
+			if err := Disconnect(networkName, cinfo); err != nil {
+				log.Errorf("disconnect network error %v", err)
+			}
+		}
+	}()
 
 	// 创建网络端点并设置
 	ep := &Endpoint{
-		ID:          fmt.Sprintf("%s-%s", cinfo.Id, networkName),
+		ID:          endpointID(cinfo, networkName),
 		IPAddress:   ip,
 		PortMapping: cinfo.PortMapping,
 		Network:     network,
 	}
 
-	// 保存容器 IP 地址
-	cinfo.IPAddress = ip.String()
-
 	// 挂载网络
 	if err = drivers[network.Driver].Connect(network, ep); err != nil {
 		return err
@@ -366,6 +399,44 @@ func Connect(networkName string, cinfo *container.ContainerInfo) error {
 	return nil
 }
 
+// 断开网络，移除端口映射和宿主机上的网络端点并释放 IP，容器信息中保留 IP 供重新启动时沿用
+// 没有连接时直接返回，IP 和端口可能已经分配给了其他容器，可以重复调用
 func Disconnect(networkName string, cinfo *container.ContainerInfo) error {
+	if !cinfo.NetworkConnected {
+		return nil
+	}
+	network, ok := networks[networkName]
+	if !ok {
+		return fmt.Errorf("no such network %s", networkName)
+	}
+	ep := &Endpoint{
+		ID:      endpointID(cinfo, networkName),
+		Network: network,
+	}
+	if err := drivers[network.Driver].Disconnect(*network, ep); err != nil {
+		return err
+	}
+	if err := removePortMapping(cinfo); err != nil {
+		return err
+	}
+	if ip := net.ParseIP(cinfo.IPAddress); ip != nil {
+		if err := ipAllocator.Release(network.IPRange, &ip); err != nil {
+			return fmt.Errorf("release ip %s error %v", cinfo.IPAddress, err)
+		}
+	}
+	cinfo.NetworkConnected = false
 	return nil
-}
\ No newline at end of file
+}
+// 获取容器网络的收发字节数，宿主机上 Veth 端点的接收即为容器的发送
+func GetEndpointStats(cinfo *container.ContainerInfo) (rxBytes, txBytes uint64, err error) {
+	device := endpointID(cinfo, cinfo.Network)[:5]
+	link, err := netlink.LinkByName(device)
+	if err != nil {
+		return 0, 0, fmt.Errorf("get link %s error %v", device, err)
+	}
+	statistics := link.Attrs().Statistics
+	if statistics == nil {
+		return 0, 0, fmt.Errorf("no statistics of link %s", device)
+	}
+	return statistics.TxBytes, statistics.RxBytes, nil
+}
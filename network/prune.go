@@ -0,0 +1,189 @@
+package network
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+	"lumper/container"
+	"net"
+	"os/exec"
+	"strings"
+)
+
+// 正在启动或重启的容器可能已经分配了 IP 和端口映射但还没有记录，返回这些容器所在的网段
+func startingSubnets(containers []*container.ContainerInfo) []*net.IPNet {
+	var subnets []*net.IPNet
+	for _, cinfo := range containers {
+		if cinfo.NetworkConnected {
+			continue
+		}
+		if nw, ok := networks[cinfo.Network]; ok {
+			_, subnet, _ := net.ParseCIDR(nw.IPRange.String())
+			subnets = append(subnets, subnet)
+		}
+	}
+	return subnets
+}
+
+// 删除不属于任何容器的 Veth 端点，返回被清理的设备名
+// containers 为占用或正在申请网络资源的容器，包括启动和重启中的容器
+func PruneEndpoints(containers []*container.ContainerInfo, dryRun bool) ([]string, error) {
+	// 容器在宿主机上的 Veth 端点名，由容器 ID 决定，启动中的容器也可以确定
+	inUse := map[string]bool{}
+	for _, cinfo := range containers {
+		if cinfo.Network != "" {
+			inUse[endpointID(cinfo, cinfo.Network)[:5]] = true
+		}
+	}
+	// lumper 创建的 Bridge 设备
+	bridges := map[int]bool{}
+	for _, nw := range networks {
+		if br, err := netlink.LinkByName(nw.Name); err == nil {
+			bridges[br.Attrs().Index] = true
+		}
+	}
+	links, err := netlink.LinkList()
+	if err != nil {
+		return nil, fmt.Errorf("list links error %v", err)
+	}
+	var pruned []string
+	for _, link := range links {
+		if link.Type() != "veth" || !bridges[link.Attrs().MasterIndex] || inUse[link.Attrs().Name] {
+			continue
+		}
+		pruned = append(pruned, link.Attrs().Name)
+		if dryRun {
+			continue
+		}
+		if err := netlink.LinkDel(link); err != nil {
+			log.Errorf("delete link %s error %v", link.Attrs().Name, err)
+		}
+	}
+	return pruned, nil
+}
+
+// 删除指向已不存在的容器的 DNAT 规则和已删除网络的 MASQUERADE 规则，返回被清理的规则
+func PruneIPTables(containers []*container.ContainerInfo, dryRun bool) ([]string, error) {
+	// 已连接网络的容器的端口映射规则
+	inUse := map[string]bool{}
+	for _, cinfo := range containers {
+		if !cinfo.NetworkConnected {
+			continue
+		}
+		for _, pm := range cinfo.PortMapping {
+			portMapping := strings.Split(pm, ":")
+			if len(portMapping) == 2 {
+				inUse[fmt.Sprintf("%s:%s", portMapping[0], cinfo.IPAddress + ":" + portMapping[1])] = true
+			}
+		}
+	}
+	// IP 分配文件中记录了 lumper 分配过的所有网段，包括已经删除的网络
+	ipAllocator.Subnets = &map[string]string{}
+	if err := ipAllocator.load(); err != nil {
+		return nil, err
+	}
+	var subnets []*net.IPNet
+	for subnetStr := range *ipAllocator.Subnets {
+		if _, subnet, err := net.ParseCIDR(subnetStr); err == nil {
+			subnets = append(subnets, subnet)
+		}
+	}
+	busy := startingSubnets(containers)
+	bridges := map[string]bool{}
+	for _, nw := range networks {
+		bridges[nw.Name] = true
+	}
+
+	var pruned []string
+	for _, chain := range []string{"PREROUTING", "POSTROUTING"} {
+		output, err := exec.Command("iptables", "-t", "nat", "-S", chain).Output()
+		if err != nil {
+			return pruned, fmt.Errorf("list iptables chain %s error %v", chain, err)
+		}
+		for _, rule := range strings.Split(string(output), "\n") {
+			if !strings.HasPrefix(rule, "-A ") || !isOrphanRule(strings.Fields(rule), subnets, busy, bridges, inUse) {
+				continue
+			}
+			deleteRule := "-t nat -D" + strings.TrimPrefix(rule, "-A")
+			pruned = append(pruned, deleteRule)
+			if dryRun {
+				continue
+			}
+			if output, err := exec.Command("iptables", strings.Fields(deleteRule)...).CombinedOutput(); err != nil {
+				log.Errorf("iptables delete rule error %v %s", err, output)
+			}
+		}
+	}
+	return pruned, nil
+}
+
+// 判断 iptables 规则是否由 lumper 创建且不再被使用，指向 busy 网段的端口映射可能属于正在启动的容器，不清理
+func isOrphanRule(fields []string, subnets, busy []*net.IPNet, bridges map[string]bool, inUse map[string]bool) bool {
+	var dport, destination, source, outIface, target string
+	for i := 0; i < len(fields) - 1; i++ {
+		switch fields[i] {
+		case "--dport":
+			dport = fields[i+1]
+		case "--to-destination":
+			destination = fields[i+1]
+		case "-s":
+			source = fields[i+1]
+		case "-o":
+			outIface = fields[i+1]
+		case "-j":
+			target = fields[i+1]
+		}
+	}
+	switch target {
+	case "DNAT":
+		// 只处理目标地址在 lumper 网段中的端口映射
+		host, _, err := net.SplitHostPort(destination)
+		if err != nil || !containsIP(subnets, net.ParseIP(host)) || containsIP(busy, net.ParseIP(host)) {
+			return false
+		}
+		return !inUse[dport + ":" + destination]
+	case "MASQUERADE":
+		// lumper 创建的规则形如 -s <subnet> ! -o <bridge> -j MASQUERADE
+		_, subnet, err := net.ParseCIDR(source)
+		if err != nil || outIface == "" || !containsIP(subnets, subnet.IP) {
+			return false
+		}
+		return !bridges[outIface]
+	}
+	return false
+}
+
+func containsIP(subnets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, subnet := range subnets {
+		if subnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// 释放没有被网络网关或容器使用的 IP，删除已不存在的网络的网段，返回被释放的地址
+func PruneIPAllocations(containers []*container.ContainerInfo, dryRun bool) ([]string, error) {
+	// 网络网关和已连接网络的容器占用的 IP，停止的容器在断开网络时已经释放 IP
+	inUse := map[string]bool{}
+	subnets := map[string]bool{}
+	for _, nw := range networks {
+		_, subnet, _ := net.ParseCIDR(nw.IPRange.String())
+		subnets[subnet.String()] = true
+		inUse[nw.IPRange.IP.String()] = true
+	}
+	for _, cinfo := range containers {
+		if cinfo.NetworkConnected && cinfo.IPAddress != "" {
+			inUse[cinfo.IPAddress] = true
+		}
+	}
+	// 正在启动的容器新分配的 IP 还没有记录，跳过其所在的网段
+	busy := map[string]bool{}
+	for _, subnet := range startingSubnets(containers) {
+		busy[subnet.String()] = true
+	}
+	return ipAllocator.prune(subnets, busy, inUse, dryRun)
+}
@@ -0,0 +1,70 @@
+package network
+
+import (
+	"lumper/container"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func mustParseCIDR(t *testing.T, cidr string) *net.IPNet {
+	_, subnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return subnet
+}
+
+func TestIsOrphanRule(t *testing.T) {
+	subnets := []*net.IPNet{mustParseCIDR(t, "192.168.50.0/24"), mustParseCIDR(t, "192.168.60.0/24")}
+	busy := []*net.IPNet{mustParseCIDR(t, "192.168.60.0/24")}
+	bridges := map[string]bool{"testbr": true}
+	inUse := map[string]bool{"8080:192.168.50.2:80": true}
+	tests := []struct {
+		rule string
+		want bool
+	}{
+		// 端口映射
+		{rule: "-A PREROUTING -p tcp -m tcp --dport 8080 -j DNAT --to-destination 192.168.50.2:80", want: false},
+		{rule: "-A PREROUTING -p tcp -m tcp --dport 8081 -j DNAT --to-destination 192.168.50.2:80", want: true},
+		{rule: "-A PREROUTING -p tcp -m tcp --dport 8080 -j DNAT --to-destination 192.168.50.3:80", want: true},
+		// 有容器正在启动的网段
+		{rule: "-A PREROUTING -p tcp -m tcp --dport 8080 -j DNAT --to-destination 192.168.60.2:80", want: false},
+		// 不是 lumper 网段的规则
+		{rule: "-A PREROUTING -p tcp -m tcp --dport 8080 -j DNAT --to-destination 10.0.0.2:80", want: false},
+		{rule: "-A PREROUTING -p tcp -m tcp --dport 8080 -j DNAT --to-destination 192.168.50.2", want: false},
+		// 网络地址转换
+		{rule: "-A POSTROUTING -s 192.168.50.0/24 ! -o testbr -j MASQUERADE", want: false},
+		{rule: "-A POSTROUTING -s 192.168.50.0/24 ! -o oldbr -j MASQUERADE", want: true},
+		{rule: "-A POSTROUTING -s 192.168.50.0/24 -j MASQUERADE", want: false},
+		{rule: "-A POSTROUTING -s 172.17.0.0/16 ! -o docker0 -j MASQUERADE", want: false},
+		{rule: "-A POSTROUTING -s 192.168.50.2 ! -o oldbr -j MASQUERADE", want: false},
+		// 其他规则
+		{rule: "-A PREROUTING -m addrtype --dst-type LOCAL -j DOCKER", want: false},
+		{rule: "-A POSTROUTING -s 192.168.50.0/24 -j ACCEPT", want: false},
+	}
+	for _, tt := range tests {
+		if got := isOrphanRule(strings.Fields(tt.rule), subnets, busy, bridges, inUse); got != tt.want {
+			t.Errorf("isOrphanRule(%q) = %v, want %v", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestStartingSubnets(t *testing.T) {
+	saved := networks
+	defer func() { networks = saved }()
+	networks = map[string]*Network{
+		"testbr": {Name: "testbr", IPRange: &net.IPNet{IP: net.ParseIP("192.168.50.1").To4(), Mask: net.CIDRMask(24, 32)}},
+		"otherbr": {Name: "otherbr", IPRange: &net.IPNet{IP: net.ParseIP("192.168.60.1").To4(), Mask: net.CIDRMask(24, 32)}},
+	}
+	containers := []*container.ContainerInfo{
+		{Name: "running", Network: "otherbr", NetworkConnected: true},
+		{Name: "starting", Network: "testbr"},
+		{Name: "unknown", Network: "nosuch"},
+	}
+	want := []*net.IPNet{mustParseCIDR(t, "192.168.50.0/24")}
+	if got := startingSubnets(containers); !reflect.DeepEqual(got, want) {
+		t.Errorf("startingSubnets() = %v, want %v", got, want)
+	}
+}
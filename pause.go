@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	"lumper/cgroups"
+	"lumper/container"
+)
+
+var pauseCommand = cli.Command{
+	Name:   "pause",
+	Usage:  "Pause all processes within a container",
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("missing container name")
+		}
+		for _, containerName := range context.Args() {
+			pauseContainer(containerName)
+		}
+		return nil
+	},
+}
+
+var unpauseCommand = cli.Command{
+	Name:   "unpause",
+	Usage:  "Unpause all processes within a container",
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("missing container name")
+		}
+		for _, containerName := range context.Args() {
+			unpauseContainer(containerName)
+		}
+		return nil
+	},
+}
+
+// 通过 freezer 冻结容器 Cgroup 中的所有进程
+func pauseContainer(containerName string) {
+	// 冻结和记录状态在容器锁内完成，避免覆盖监控进程同时记录的退出状态
+	containerInfo, err := updateContainerInfo(containerName, func(containerInfo *container.ContainerInfo) error {
+		if containerInfo.Status == container.PAUSED {
+			return fmt.Errorf("container is already paused")
+		}
+		if containerInfo.Status != container.RUNNING {
+			return errContainerNotRunning
+		}
+		if err := cgroups.NewCgroupManager(containerInfo.GetCgroupPath()).Freeze(); err != nil {
+			return err
+		}
+		containerInfo.Status = container.PAUSED
+		return nil
+	})
+	if err != nil {
+		log.Errorf("pause container %s error %v", containerName, err)
+		return
+	}
+	logContainerEvent("pause", containerInfo, nil)
+}
+
+// 解冻容器 Cgroup 中的所有进程
+func unpauseContainer(containerName string) {
+	containerInfo, err := thawContainer(containerName)
+	if err != nil {
+		log.Errorf("unpause container %s error %v", containerName, err)
+		return
+	}
+	logContainerEvent("unpause", containerInfo, nil)
+}
+
+// 在容器锁内解冻并记录为运行中，进程解冻后立即退出时监控进程在释放锁后才会记录退出状态
+func thawContainer(containerName string) (*container.ContainerInfo, error) {
+	return updateContainerInfo(containerName, func(containerInfo *container.ContainerInfo) error {
+		if containerInfo.Status != container.PAUSED {
+			return fmt.Errorf("container is not paused")
+		}
+		if err := cgroups.NewCgroupManager(containerInfo.GetCgroupPath()).Thaw(); err != nil {
+			return err
+		}
+		containerInfo.Status = container.RUNNING
+		return nil
+	})
+}
@@ -4,8 +4,9 @@ import (
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
+	"lumper/cgroups"
+	"golang.org/x/sys/unix"
 	"lumper/container"
-	"lumper/network"
 	"os"
 )
 
@@ -13,37 +14,104 @@ var removeCommand = cli.Command{
 	Name:   "remove",
 	Aliases: []string{"rm"},
 	Usage:  "Remove unused container",
+	Flags:  []cli.Flag{
+		cli.BoolFlag{
+			Name:  "force, f",
+			Usage: "force the removal of a running container",
+		},
+		cli.BoolFlag{
+			Name:  "volumes, v",
+			Usage: "remove anonymous volumes associated with the container",
+		},
+	},
 	Action: func(context *cli.Context) error {
 		if len(context.Args()) < 1 {
 			return fmt.Errorf("missing container name")
 		}
-		containerName := context.Args().Get(0)
-		removeContainer(containerName)
+		for _, containerName := range context.Args() {
+			removeContainer(containerName, context.Bool("force"), context.Bool("volumes"))
+		}
 		return nil
 	},
 }
 
-func removeContainer(containerName string)  {
-	containerInfo, err := getContainerInfoByName(containerName)
+func removeContainer(containerName string, force, removeVolumes bool)  {
+	if force {
+		if err := forceStopContainer(containerName); err != nil {
+			log.Errorf("stop container %s error %v", containerName, err)
+			return
+		}
+	}
+	// 检查状态和删除在同一把锁内完成，避免监控进程在此期间重启容器
+	unlock, err := lockContainer(containerName)
 	if err != nil {
 		log.Errorf("get container %s info error %v", containerName, err)
 		return
 	}
-	if containerInfo.Status != container.STOP {
-		log.Errorf("couldn't remove running container")
+	defer unlock()
+	containerInfo, err := loadContainerInfoLocked(containerName)
+	if err != nil {
+		log.Errorf("get container %s info error %v", containerName, err)
 		return
 	}
-	// 删除时释放网络
-	if containerInfo.Network != "" {
-		network.Init()
-		if err = network.ReleaseContainerNetwork(containerInfo); err != nil {
-			log.Errorf("release container network error %v", err)
-			return
+	if containerInfo.Status == container.RUNNING || containerInfo.Status == container.PAUSED || containerInfo.Status == container.RESTARTING {
+		if force {
+			log.Errorf("couldn't stop container %s", containerName)
+		} else {
+			log.Errorf("couldn't remove running container")
 		}
-	}
-	dirUrl := fmt.Sprintf(container.DefaultInfoLocation, containerName)
-	if err := os.RemoveAll(dirUrl); err != nil {
-		log.Errorf("remove file %s error %v", dirUrl, err)
 		return
 	}
-}
\ No newline at end of file
+	if err := teardownContainer(containerInfo, removeVolumes); err != nil {
+		log.Errorf("remove container %s error %v", containerName, err)
+	}
+}
+
+// 强制删除时直接杀掉容器，先标记为手动停止，监控进程不再重启容器
+func forceStopContainer(containerName string) error {
+	containerInfo, err := updateContainerInfo(containerName, func(containerInfo *container.ContainerInfo) error {
+		containerInfo.ManuallyStopped = true
+		if containerInfo.Status == container.RESTARTING {
+			containerInfo.Status = container.STOP
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// 冻结的进程在解冻后才会处理 SIGKILL
+	if containerInfo.Status == container.PAUSED {
+		if _, err := thawContainer(containerName); err != nil {
+			return err
+		}
+	}
+	if containerInfo.Status == container.RUNNING || containerInfo.Status == container.PAUSED {
+		if err := killContainer(containerName, unix.SIGKILL); err != nil && err != errContainerNotRunning {
+			return err
+		}
+		waitContainerStop(containerName, stopKillTimeout)
+	}
+	return nil
+}
+
+// 释放容器占用的网络、工作空间和数据卷，最后删除容器信息，调用方持有容器锁
+func teardownContainer(containerInfo *container.ContainerInfo, removeVolumes bool) error {
+	// 退出时断开网络失败或还没有被监控进程回收的容器仍然占用网络资源
+	disconnectContainerNetwork(containerInfo)
+	// 容器删除时才释放 Cgroup，旧版本容器共用的 Cgroup 由 system prune 清理
+	if containerInfo.CgroupPath != "" {
+		cgroups.NewCgroupManager(containerInfo.CgroupPath).Destroy()
+	}
+	if err := container.DeleteWorkSpace(containerInfo.Volume, containerInfo.Name, containerInfo.Image); err != nil {
+		return fmt.Errorf("delete workspace error %v", err)
+	}
+	// 只删除匿名数据卷，指定宿主机目录的数据卷由用户管理
+	if volumeUrls := container.ParseVolume(containerInfo.Volume); removeVolumes && volumeUrls != nil && container.IsAnonymousVolume(volumeUrls[0]) {
+		if err := os.RemoveAll(volumeUrls[0]); err != nil {
+			log.Errorf("remove volume %s error %v", volumeUrls[0], err)
+		}
+	}
+	deleteContainerInfo(containerInfo.Name)
+	logContainerEvent("rm", containerInfo, nil)
+	return nil
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
+	"golang.org/x/sys/unix"
+	"io/ioutil"
 	"lumper/cgroups/subsystems"
 	"lumper/network"
 	"os"
@@ -13,6 +15,10 @@ import (
 	"strings"
 	"lumper/cgroups"
 	"math/rand"
+	"os/exec"
+	"os/signal"
+	"path"
+	"syscall"
 	"time"
 )
 
@@ -20,6 +26,8 @@ import (
 var runCommand = cli.Command{
 	Name:   "run",
 	Usage:  "Create a container",
+	// 镜像名之后的参数都属于容器命令，如 sh -c 中的 -c
+	SkipArgReorder: true,
 	Action: func(context *cli.Context) error {
 		if len(context.Args()) < 1 {
 			return fmt.Errorf("missing container command")
@@ -43,14 +51,114 @@ var runCommand = cli.Command{
 			MemoryLimit: context.String("memory"),
 			CpuShare: context.String("cpushare"),
 			CpuSet: context.String("cpuset"),
+			CpuSetMems: context.String("cpuset-mems"),
+			CpuQuota: context.String("cpu-quota"),
+			CpuPeriod: context.String("cpu-period"),
+			PidsLimit: context.String("pids-limit"),
+			BlkioWeight: context.String("blkio-weight"),
+			MemorySwap: context.String("memory-swap"),
+			MemoryReservation: context.String("memory-reservation"),
+			KernelMemory: context.String("kernel-memory"),
+			OomKillDisable: context.Bool("oom-kill-disable"),
+			OomScoreAdj: context.String("oom-score-adj"),
+		}
+		// 将设备路径转换成设备号
+		throttles := []struct{
+			flag     string
+			bytes    bool
+			throttle *[]string
+		}{
+			{"device-read-bps", true, &resConf.DeviceReadBps},
+			{"device-write-bps", true, &resConf.DeviceWriteBps},
+			{"device-read-iops", false, &resConf.DeviceReadIOps},
+			{"device-write-iops", false, &resConf.DeviceWriteIOps},
+		}
+		for _, t := range throttles {
+			for _, device := range context.StringSlice(t.flag) {
+				parsed, err := subsystems.ParseThrottleDevice(device, t.bytes)
+				if err != nil {
+					return err
+				}
+				*t.throttle = append(*t.throttle, parsed)
+			}
+		}
+		// --cpus 是 --cpu-quota 和 --cpu-period 的简便写法
+		if cpus := context.String("cpus"); cpus != "" {
+			if resConf.CpuQuota != "" || resConf.CpuPeriod != "" {
+				return fmt.Errorf("cpus and cpu-quota/cpu-period cannot be used together")
+			}
+			quota, period, err := subsystems.CpusToQuota(cpus)
+			if err != nil {
+				return err
+			}
+			resConf.CpuQuota, resConf.CpuPeriod = quota, period
+		}
+		// 透传的宿主机设备，与标准设备一起加入设备白名单
+		var devices []container.Device
+		for _, spec := range context.StringSlice("device") {
+			device, err := container.ParseDevice(spec)
+			if err != nil {
+				return err
+			}
+			devices = append(devices, *device)
+		}
+		resConf.Devices = container.DeviceRules(devices)
+		// 启动前检查所有资源限制，避免容器在限制没有生效的情况下运行
+		if err := resConf.Validate(); err != nil {
+			return err
+		}
+		if resConf.OomKillDisable && resConf.MemoryLimit == "" {
+			log.Warnf("disabling oom kill without memory limit may hang the host")
 		}
 		containerName := context.String("name")
 		volume := context.String("volume")
-		env := context.StringSlice("env")
+		// --env-file 中的变量先于 -e，同名时以 -e 为准
+		var env []string
+		for _, envFile := range context.StringSlice("env-file") {
+			fileEnv, err := container.ParseEnvFile(envFile)
+			if err != nil {
+				return err
+			}
+			env = append(env, fileEnv...)
+		}
+		env = append(env, container.ParseEnv(context.StringSlice("env"))...)
 		nw := context.String("net")
 		portmapping := context.StringSlice("port")
-		// 启动容器
-		Run(tty, cmdArray, env, portmapping, resConf, containerName, volume, imageName, nw)
+		cgroupParent := context.String("cgroup-parent")
+		if cgroupParent == "" || strings.Contains(cgroupParent, "..") {
+			return fmt.Errorf("invalid cgroup parent %s", cgroupParent)
+		}
+		labels, err := parseLabels(context.StringSlice("label"))
+		if err != nil {
+			return err
+		}
+		process := container.ProcessConfig{
+			Hostname:   context.String("hostname"),
+			User:       context.String("user"),
+			WorkingDir: context.String("workdir"),
+		}
+		if process.WorkingDir != "" && !path.IsAbs(process.WorkingDir) {
+			return fmt.Errorf("workdir %s should be an absolute path", process.WorkingDir)
+		}
+		for _, ulimit := range context.StringSlice("ulimit") {
+			rlimit, err := container.ParseRlimit(ulimit)
+			if err != nil {
+				return err
+			}
+			process.Rlimits = append(process.Rlimits, *rlimit)
+		}
+		restartPolicy, err := container.ParseRestartPolicy(context.String("restart"))
+		if err != nil {
+			return err
+		}
+		// 前台运行的容器退出后会被删除，不支持重启
+		if tty && restartPolicy.Name != container.RestartNo {
+			return fmt.Errorf("restart policy %s cannot be used with tty", restartPolicy)
+		}
+		// 启动容器，前台运行时 lumper 的退出码与容器一致
+		if exitCode := Run(tty, cmdArray, env, portmapping, labels, devices, process, resConf, containerName, volume, imageName, nw, cgroupParent, restartPolicy); exitCode != 0 {
+			return cli.NewExitError("", exitCode)
+		}
 		return nil
 	},
 	Flags:  []cli.Flag{
@@ -62,6 +170,26 @@ var runCommand = cli.Command{
 			Name:  "memory, m",
 			Usage: "memory limit",
 		},
+		cli.StringFlag{
+			Name:  "memory-swap",
+			Usage: "total memory limit (memory + swap), -1 for unlimited swap",
+		},
+		cli.StringFlag{
+			Name:  "memory-reservation",
+			Usage: "memory soft limit",
+		},
+		cli.StringFlag{
+			Name:  "kernel-memory",
+			Usage: "kernel memory limit",
+		},
+		cli.BoolFlag{
+			Name:  "oom-kill-disable",
+			Usage: "disable oom killer",
+		},
+		cli.StringFlag{
+			Name:  "oom-score-adj",
+			Usage: "tune oom score adj of container init process (-1000 to 1000)",
+		},
 		cli.StringFlag{
 			Name:  "cpushare, c",
 			Usage: "cpushare limit",
@@ -70,6 +198,50 @@ var runCommand = cli.Command{
 			Name:  "cpuset",
 			Usage: "cpuset limit",
 		},
+		cli.StringSliceFlag{
+			Name:  "device",
+			Usage: "add a host device to the container, host[:container][:rwm]",
+		},
+		cli.StringFlag{
+			Name:  "cpuset-mems",
+			Usage: "memory nodes (0-3, 0,1)",
+		},
+		cli.StringFlag{
+			Name:  "cpus",
+			Usage: "number of cpus, e.g. 1.5",
+		},
+		cli.StringFlag{
+			Name:  "cpu-quota",
+			Usage: "cpu cfs quota in microseconds",
+		},
+		cli.StringFlag{
+			Name:  "cpu-period",
+			Usage: "cpu cfs period in microseconds",
+		},
+		cli.StringFlag{
+			Name:  "pids-limit",
+			Usage: "pids limit, -1 for unlimited",
+		},
+		cli.StringFlag{
+			Name:  "blkio-weight",
+			Usage: "block io weight, between 10 and 1000",
+		},
+		cli.StringSliceFlag{
+			Name:  "device-read-bps",
+			Usage: "limit read rate from a device, <device-path>:<bytes-per-second>",
+		},
+		cli.StringSliceFlag{
+			Name:  "device-write-bps",
+			Usage: "limit write rate to a device, <device-path>:<bytes-per-second>",
+		},
+		cli.StringSliceFlag{
+			Name:  "device-read-iops",
+			Usage: "limit read rate from a device, <device-path>:<io-per-second>",
+		},
+		cli.StringSliceFlag{
+			Name:  "device-write-iops",
+			Usage: "limit write rate to a device, <device-path>:<io-per-second>",
+		},
 		cli.BoolFlag{
 			Name:  "detach, d",
 			Usage: "detach container",
@@ -86,6 +258,10 @@ var runCommand = cli.Command{
 			Name:  "env, e",
 			Usage: "set environment",
 		},
+		cli.StringSliceFlag{
+			Name:  "env-file",
+			Usage: "read in a file of environment variables",
+		},
 		cli.StringFlag{
 			Name:  "net",
 			Usage: "container network",
@@ -94,73 +270,340 @@ var runCommand = cli.Command{
 			Name: "port, p",
 			Usage: "port mapping",
 		},
+		cli.StringSliceFlag{
+			Name:  "label, l",
+			Usage: "set metadata on container, key=value",
+		},
+		cli.StringFlag{
+			Name:  "cgroup-parent",
+			Value: container.DefaultCgroupParent,
+			Usage: "parent cgroup for the container",
+		},
+		cli.StringFlag{
+			Name:  "hostname",
+			Usage: "container host name, default container ID",
+		},
+		cli.StringFlag{
+			Name:  "user, u",
+			Usage: "username or UID, format <name|uid>[:<group|gid>]",
+		},
+		cli.StringFlag{
+			Name:  "workdir, w",
+			Usage: "working directory inside the container",
+		},
+		cli.StringSliceFlag{
+			Name:  "ulimit",
+			Usage: "ulimit options, <name>=<soft>[:<hard>]",
+		},
+		cli.StringFlag{
+			Name:  "restart",
+			Value: container.RestartNo,
+			Usage: "restart policy, no|always|on-failure[:max-retries]|unless-stopped",
+		},
 	},
 }
 
-func Run(tty bool, cmdArray, env, portmapping []string, res * subsystems.ResourceConfig, containerName, volume, imageName, nw string)  {
+func Run(tty bool, cmdArray, env, portmapping []string, labels map[string]string, devices []container.Device, process container.ProcessConfig, res * subsystems.ResourceConfig, containerName, volume, imageName, nw, cgroupParent string, restartPolicy container.RestartPolicy) int {
 	containerID := randStringBytes(12)
 	if containerName == "" {
 		containerName = containerID
 	}
-	parent, writePipe := container.NewParentProcess(tty, containerName, volume, imageName, env)
-	if parent == nil {
-		log.Errorf("new parent process error")
-		return
+	// 默认以容器 ID 作为主机名
+	if process.Hostname == "" {
+		process.Hostname = containerID
 	}
-	if err := parent.Start(); err != nil {
-		log.Error(err)
+
+	// 容器环境变量依次为默认值、镜像中定义的和用户指定的，不继承宿主机的环境变量
+	imageEnv, err := container.ReadImageEnv(imageName)
+	if err != nil {
+		log.Errorf("read image env error %v", err)
+		return 1
 	}
+	env = container.MergeEnv(container.DefaultEnv(process.Hostname, tty), imageEnv, env)
 
-	createTime := time.Now().Format("2006/1/2 15:04:05")
-	command := strings.Join(cmdArray, "")
+	createTime := time.Now().Format(container.CreatedTimeLayout)
+	command := strings.Join(cmdArray, " ")
 	containerInfo := &container.ContainerInfo{
-		Pid:         strconv.Itoa(parent.Process.Pid),
 		Id:          containerID,
 		Name:        containerName,
 		Command:     command,
 		CreatedTime: createTime,
-		Status:      container.RUNNING,
+		Status:      container.CREATED,
 		Network:	 nw,
-		Volume:      volume,
+		Volume:      container.NormalizeVolume(volume, containerName),
 		PortMapping: portmapping,
+		Image:       imageName,
+		Args:        cmdArray,
+		Env:         env,
+		Resource:    res,
+		CgroupPath:  path.Join(cgroupParent, containerID),
+		RestartPolicy: restartPolicy,
+		Labels:      labels,
+		Devices:     devices,
+		ProcessConfig: process,
+		// 由 shim 接管前当前进程负责启动，进程意外退出时容器会被修正为已退出
+		ShimPid:     strconv.Itoa(os.Getpid()),
+	}
+	if err := createContainerInfoDir(containerName); err != nil {
+		log.Errorf("create container %s error %v", containerName, err)
+		return 1
 	}
+	if _, err := recordContainerInfo(containerInfo); err != nil {
+		log.Errorf("record container info error %v", err)
+		return 1
+	}
+	logContainerEvent("create", containerInfo, nil)
 
-	// 创建 Cgroup Manager
-	cgroupManager := cgroups.NewCgroupManager("lumper-cgroup")
-	defer cgroupManager.Destroy()
-	cgroupManager.Set(res)
-	cgroupManager.Apply(parent.Process.Pid)
+	// 后台运行的容器交给 shim 进程监控
+	if !tty {
+		if err := startShim(containerName); err != nil {
+			log.Errorf("start container %s error %v", containerName, err)
+			// shim 没有启动容器时由当前进程记录启动失败
+			updateContainerInfo(containerName, func(containerInfo *container.ContainerInfo) error {
+				if containerInfo.Status != container.CREATED {
+					return errContainerNotRunning
+				}
+				containerInfo.ExitCode = container.ExitCodeStartFailed
+				containerInfo.FinishedAt = time.Now()
+				containerInfo.Status = container.EXIT
+				return nil
+			})
+			return 1
+		}
+		return 0
+	}
 
-	if nw != "" {
-		network.Init()
-		if err := network.Connect(nw, containerInfo); err != nil {
-			log.Errorf("connect network error %v", err)
+	// 前台运行的容器由当前进程监控，并将收到的信号转发给容器
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, unix.SIGTERM, unix.SIGINT, unix.SIGQUIT, unix.SIGHUP)
+	defer signal.Stop(sigs)
+	unlock, err := lockContainer(containerName)
+	if err != nil {
+		log.Errorf("get container %s info error %v", containerName, err)
+		return 1
+	}
+	parent, err := startContainer(containerInfo, tty)
+	unlock()
+	if err != nil {
+		// 启动失败的容器与后台运行的一样保留记录，返回记录的退出码
+		log.Errorf("start container %s error %v", containerName, err)
+		return containerInfo.ExitCode
+	}
+	go forwardSignals(sigs, parent.Process)
+
+	exitCode := waitContainer(parent, containerInfo)
+	// 前台运行的容器退出后删除
+	if unlock, err := lockContainer(containerName); err == nil {
+		if err := teardownContainer(containerInfo, true); err != nil {
+			log.Errorf("remove container %s error %v", containerName, err)
+		}
+		unlock()
+	}
+	return exitCode
+}
+
+// 容器进程与当前进程在同一个前台进程组，终端产生的信号已经发送给了容器，
+// 当前进程只接收这些信号以便等待容器退出后清理，不再重复转发
+var terminalSignals = map[os.Signal]bool{
+	unix.SIGINT:  true,
+	unix.SIGQUIT: true,
+	unix.SIGHUP:  true,
+}
+
+// 将信号转发给容器 init 进程
+func forwardSignals(sigs chan os.Signal, process *os.Process) {
+	for sig := range sigs {
+		if terminalSignals[sig] {
+			continue
+		}
+		if err := process.Signal(sig); err != nil {
+			log.Errorf("forward signal %v to container error %v", sig, err)
 			return
 		}
 	}
+}
 
-	containerName, err := recordContainerInfo(containerInfo)
+// 启动容器 init 进程，加入 Cgroup 和网络后发送启动配置，等待 init 执行用户命令，调用方持有容器锁
+func startContainer(containerInfo *container.ContainerInfo, tty bool) (*exec.Cmd, error) {
+	parent, writePipe, errorPipe := container.NewParentProcess(tty, containerInfo.Name, containerInfo.Volume, containerInfo.Image)
+	if parent == nil {
+		failContainerStart(containerInfo, container.ExitCodeStartFailed)
+		return nil, fmt.Errorf("new parent process error")
+	}
+	// 启动配置由 SendInitSpec 发送后关闭管道，在此之前失败时在这里关闭
+	specSent := false
+	defer func() {
+		if !specSent {
+			writePipe.Close()
+		}
+	}()
+	defer errorPipe.Close()
+	err := parent.Start()
+	// 关闭父进程中属于 init 进程的管道端，init 退出后才能读到 EOF
+	for _, file := range parent.ExtraFiles {
+		file.Close()
+	}
+	// 容器日志文件已由 init 进程继承，shim 每次重启都会重新打开，不关闭会泄漏文件描述符
+	if logFile, ok := parent.Stdout.(*os.File); ok && logFile != os.Stdout {
+		logFile.Close()
+	}
 	if err != nil {
-		log.Errorf("record container info error %v", err)
-		return
+		failContainerStart(containerInfo, container.ExitCodeStartFailed)
+		return nil, err
+	}
+	containerInfo.Pid = strconv.Itoa(parent.Process.Pid)
+	if startTime, err := getProcessStartTime(parent.Process.Pid); err == nil {
+		containerInfo.PidStartTime = startTime
 	}
+	containerInfo.ShimPid = strconv.Itoa(os.Getpid())
+	containerInfo.Status = container.RUNNING
+	containerInfo.StartedAt = time.Now()
 
-	sendInitCommand(cmdArray, writePipe)
-	if tty {
-		parent.Wait()
-		deleteContainerInfo(containerName)
-		container.DeleteWorkSpace(volume, containerName, imageName)
-		if nw != "" {
-			network.ReleaseContainerNetwork(containerInfo)
+	// 创建 Cgroup Manager，每个容器使用单独的 Cgroup
+	cgroupManager := cgroups.NewCgroupManager(containerInfo.GetCgroupPath())
+	if containerInfo.Resource != nil {
+		if err := cgroupManager.Set(containerInfo.Resource); err != nil {
+			killContainerProcess(parent, containerInfo)
+			return nil, fmt.Errorf("set cgroup resource error %v", err)
+		}
+	}
+	if err := cgroupManager.Apply(parent.Process.Pid); err != nil {
+		killContainerProcess(parent, containerInfo)
+		return nil, fmt.Errorf("apply cgroup error %v", err)
+	}
+	// 记录启动时的 OOM kill 计数，退出时据此判断容器是否因内存不足被杀死
+	if count, err := cgroupManager.OOMKillCount(); err == nil {
+		containerInfo.OOMKillCount = count
+	}
+	containerInfo.OOMKilled = false
+	// oom_score_adj 会被容器内的子进程继承
+	if containerInfo.Resource != nil && containerInfo.Resource.OomScoreAdj != "" {
+		oomScoreAdj := fmt.Sprintf("/proc/%d/oom_score_adj", parent.Process.Pid)
+		if err := ioutil.WriteFile(oomScoreAdj, []byte(containerInfo.Resource.OomScoreAdj), 0644); err != nil {
+			killContainerProcess(parent, containerInfo)
+			return nil, fmt.Errorf("set oom score adj error %v", err)
 		}
 	}
+
+	if containerInfo.Network != "" {
+		network.Init()
+		if err := network.Connect(containerInfo.Network, containerInfo); err != nil {
+			killContainerProcess(parent, containerInfo)
+			return nil, fmt.Errorf("connect network error %v", err)
+		}
+		logNetworkEvent("connect", containerInfo)
+	}
+
+	if _, err := recordContainerInfo(containerInfo); err != nil {
+		killContainerProcess(parent, containerInfo)
+		return nil, fmt.Errorf("record container info error %v", err)
+	}
+
+	specSent = true
+	if err := container.SendInitSpec(container.NewInitSpec(containerInfo), writePipe); err != nil {
+		killContainerProcess(parent, containerInfo)
+		return nil, err
+	}
+	// init 执行用户命令失败后会退出，记录退出码，用户命令没有启动，不记录 die 事件
+	if err := container.WaitInitError(errorPipe); err != nil {
+		parent.Wait()
+		failContainerStart(containerInfo, exitStatus(parent.ProcessState))
+		return nil, err
+	}
+	logContainerEvent("start", containerInfo, nil)
+	return parent, nil
 }
 
-func sendInitCommand(cmdArray []string, writePipe *os.File)  {
-	command := strings.Join(cmdArray, " ")
-	log.Infof("command all is %s", command)
-	writePipe.WriteString(command)
-	writePipe.Close()
+// 容器退出后断开网络并释放 IP，没有连接网络时不做处理
+func disconnectContainerNetwork(containerInfo *container.ContainerInfo) {
+	if containerInfo.Network == "" || !containerInfo.NetworkConnected {
+		return
+	}
+	network.Init()
+	if err := network.Disconnect(containerInfo.Network, containerInfo); err != nil {
+		log.Errorf("disconnect network error %v", err)
+	} else {
+		logNetworkEvent("disconnect", containerInfo)
+	}
+}
+
+// 启动失败的容器记录为已退出，保留容器信息供查看，用户命令无法启动时记录 init 的退出码
+func failContainerStart(containerInfo *container.ContainerInfo, exitCode int) {
+	disconnectContainerNetwork(containerInfo)
+	containerInfo.Pid = ""
+	containerInfo.ExitCode = exitCode
+	containerInfo.FinishedAt = time.Now()
+	containerInfo.Status = container.EXIT
+	if _, err := recordContainerInfo(containerInfo); err != nil {
+		log.Errorf("record container info error %v", err)
+	}
+}
+
+// 启动失败时杀掉已经创建的 init 进程
+func killContainerProcess(parent *exec.Cmd, containerInfo *container.ContainerInfo) {
+	if err := parent.Process.Kill(); err != nil {
+		log.Errorf("kill container process error %v", err)
+	}
+	parent.Wait()
+	failContainerStart(containerInfo, container.ExitCodeStartFailed)
+}
+
+// 等待容器 init 进程退出，记录退出码和退出时间，返回退出码
+func waitContainer(parent *exec.Cmd, containerInfo *container.ContainerInfo) int {
+	if err := parent.Wait(); err != nil {
+		log.Infof("container %s exit %v", containerInfo.Name, err)
+	}
+	exitCode := exitStatus(parent.ProcessState)
+	finishedAt := time.Now()
+
+	// 容器运行期间配置可能被其他命令修改，在容器锁内以磁盘上的为准修改并记录
+	unlock, err := lockContainer(containerInfo.Name)
+	if err != nil {
+		disconnectContainerNetwork(containerInfo)
+		return exitCode
+	}
+	defer unlock()
+	latest, err := getContainerInfoByName(containerInfo.Name)
+	if err != nil {
+		// 容器信息已被删除时不再记录，避免重新创建容器目录
+		disconnectContainerNetwork(containerInfo)
+		return exitCode
+	}
+	*containerInfo = *latest
+	disconnectContainerNetwork(containerInfo)
+	containerInfo.Pid = ""
+	containerInfo.ExitCode = exitCode
+	// OOM kill 计数比启动时增加说明容器内有进程因内存不足被杀死
+	if count, err := cgroups.NewCgroupManager(containerInfo.GetCgroupPath()).OOMKillCount(); err == nil && count > containerInfo.OOMKillCount {
+		containerInfo.OOMKilled = true
+		containerInfo.OOMKillCount = count
+	}
+	containerInfo.FinishedAt = finishedAt
+	if containerInfo.ManuallyStopped {
+		containerInfo.Status = container.STOP
+	} else {
+		containerInfo.Status = container.EXIT
+	}
+	if _, err := recordContainerInfo(containerInfo); err != nil {
+		log.Errorf("record container info error %v", err)
+	}
+	if containerInfo.OOMKilled {
+		logContainerEvent("oom", containerInfo, nil)
+	}
+	logContainerEvent("die", containerInfo, map[string]string{"exitCode": strconv.Itoa(exitCode)})
+	return exitCode
+}
+
+// 计算进程退出码，被信号杀死时为 128 + 信号值
+func exitStatus(state *os.ProcessState) int {
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok {
+		return state.ExitCode()
+	}
+	if status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return status.ExitStatus()
 }
 
 // 记录容器信息
@@ -181,19 +624,40 @@ func recordContainerInfo(cinfo *container.ContainerInfo) (string, error) {
 		return "", err
 	}
 	fileName := dirUrl + container.ConfigName
-	file, err := os.Create(fileName)
-	defer file.Close()
+	// 先写入临时文件再重命名，避免 shim 和其他命令同时读写时读到不完整的配置
+	tmpFileName := fileName + ".tmp"
+	file, err := os.Create(tmpFileName)
 	if err != nil {
-		log.Errorf("create file %s error %v", fileName, err)
+		log.Errorf("create file %s error %v", tmpFileName, err)
 		return "", err
 	}
+	defer file.Close()
 	if _, err := file.WriteString(jsonStr); err != nil {
 		log.Errorf("file write string error %v", err)
 		return "", err
 	}
+	if err := os.Rename(tmpFileName, fileName); err != nil {
+		log.Errorf("rename file %s error %v", tmpFileName, err)
+		return "", err
+	}
 	return cinfo.Name, nil
 }
 
+// 创建容器信息目录，目录已存在说明容器名已被其他容器使用
+func createContainerInfoDir(containerName string) error {
+	dirUrl := fmt.Sprintf(container.DefaultInfoLocation, containerName)
+	if err := os.MkdirAll(path.Dir(path.Clean(dirUrl)), 0622); err != nil {
+		return err
+	}
+	if err := os.Mkdir(dirUrl, 0622); err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("container name %s is already in use, remove it or use a different name", containerName)
+		}
+		return err
+	}
+	return nil
+}
+
 // 删除容器信息
 func deleteContainerInfo(containerId string)  {
 	dirUrl := fmt.Sprintf(container.DefaultInfoLocation, containerId)
@@ -202,6 +666,23 @@ func deleteContainerInfo(containerId string)  {
 	}
 }
 
+// 解析 key=value 形式的标签
+func parseLabels(rawLabels []string) (map[string]string, error) {
+	labels := map[string]string{}
+	for _, rawLabel := range rawLabels {
+		kv := strings.SplitN(rawLabel, "=", 2)
+		if kv[0] == "" {
+			return nil, fmt.Errorf("invalid label %s", rawLabel)
+		}
+		if len(kv) == 1 {
+			labels[kv[0]] = ""
+		} else {
+			labels[kv[0]] = kv[1]
+		}
+	}
+	return labels, nil
+}
+
 // 随机生成 n 位数的字符串
 func randStringBytes(n int) string {
 	letterBytes := "1234567890"
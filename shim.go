@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	"golang.org/x/sys/unix"
+	"io/ioutil"
+	"lumper/container"
+	"os"
+	"os/exec"
+	"time"
+)
+
+const (
+	// 重启退避时间的初始值和上限
+	restartBackoffMin = 100 * time.Millisecond
+	restartBackoffMax = time.Minute
+	// 容器运行超过该时间后退出，重启时重置退避时间
+	restartBackoffReset = 10 * time.Second
+)
+
+// 监控容器进程，作为容器 init 进程的父进程常驻，容器退出后记录退出状态
+var shimCommand = cli.Command{
+	Name:   "shim",
+	Usage:  "Supervise container process",
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("missing container name")
+		}
+		containerName := context.Args().Get(0)
+		return runShim(containerName)
+	},
+}
+
+// 启动 shim 进程，等待其返回容器启动结果
+func startShim(containerName string) error {
+	readPipe, writePipe, err := container.NewPipe()
+	if err != nil {
+		return fmt.Errorf("new pipe error %v", err)
+	}
+	defer readPipe.Close()
+
+	cmd := exec.Command("/proc/self/exe", "shim", containerName)
+	// 创建新的会话，使 shim 脱离当前终端，不会随 lumper 命令退出
+	cmd.SysProcAttr = &unix.SysProcAttr{
+		Setsid: true,
+	}
+	// 传入管道文件写入端的句柄，用于回传启动结果
+	cmd.ExtraFiles = []*os.File{writePipe}
+	if err := cmd.Start(); err != nil {
+		writePipe.Close()
+		return err
+	}
+	writePipe.Close()
+
+	// shim 启动容器成功后关闭管道，失败则写入错误信息
+	msg, err := ioutil.ReadAll(readPipe)
+	if err != nil {
+		return fmt.Errorf("read shim pipe error %v", err)
+	}
+	if len(msg) > 0 {
+		return fmt.Errorf("%s", msg)
+	}
+	return cmd.Process.Release()
+}
+
+func runShim(containerName string) error {
+	syncPipe := os.NewFile(uintptr(3), "pipe")
+
+	// shim 的输出不再关联终端，日志写入容器目录
+	dirUrl := fmt.Sprintf(container.DefaultInfoLocation, containerName)
+	shimLogFilePath := dirUrl + container.ShimLogFile
+	shimLogFile, err := os.OpenFile(shimLogFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err == nil {
+		log.SetOutput(shimLogFile)
+		defer shimLogFile.Close()
+	}
+
+	// 启动期间持有容器锁，其他命令在容器记录为运行中之后才能读取和修改容器信息
+	unlock, err := lockContainer(containerName)
+	if err != nil {
+		syncPipe.WriteString(fmt.Sprintf("get container %s info error %v", containerName, err))
+		syncPipe.Close()
+		return err
+	}
+	containerInfo, err := getContainerInfoByName(containerName)
+	if err != nil {
+		unlock()
+		syncPipe.WriteString(fmt.Sprintf("get container %s info error %v", containerName, err))
+		syncPipe.Close()
+		return err
+	}
+	parent, err := startContainer(containerInfo, false)
+	unlock()
+	if err != nil {
+		syncPipe.WriteString(err.Error())
+		syncPipe.Close()
+		return err
+	}
+	syncPipe.Close()
+
+	backoff := restartBackoffMin
+	for {
+		exitCode := waitContainer(parent, containerInfo)
+		log.Infof("container %s exited with code %d", containerName, exitCode)
+		if !containerInfo.RestartPolicy.ShouldRestart(exitCode, containerInfo.RestartCount, containerInfo.ManuallyStopped) {
+			return nil
+		}
+
+		// 容器运行足够长时间后再退出，重置退避时间
+		if containerInfo.FinishedAt.Sub(containerInfo.StartedAt) > restartBackoffReset {
+			backoff = restartBackoffMin
+		}
+		// 记录退出状态后容器可能已被手动停止，在锁内确认后再记录为重启中
+		latest, err := updateContainerInfo(containerName, func(latest *container.ContainerInfo) error {
+			if latest.ManuallyStopped {
+				return errContainerNotRunning
+			}
+			latest.Status = container.RESTARTING
+			return nil
+		})
+		if err != nil {
+			if err != errContainerNotRunning {
+				log.Errorf("record container info error %v", err)
+			}
+			return nil
+		}
+		*containerInfo = *latest
+		log.Infof("restart container %s in %v", containerName, backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > restartBackoffMax {
+			backoff = restartBackoffMax
+		}
+
+		// 等待期间容器可能被手动停止或删除，确认和重新启动在同一把锁内完成
+		if unlock, err = lockContainer(containerName); err != nil {
+			return err
+		}
+		latest, err = getContainerInfoByName(containerName)
+		if err != nil {
+			unlock()
+			return err
+		}
+		*containerInfo = *latest
+		if containerInfo.ManuallyStopped {
+			unlock()
+			return nil
+		}
+		containerInfo.RestartCount++
+		parent, err = startContainer(containerInfo, false)
+		unlock()
+		if err != nil {
+			log.Errorf("restart container %s error %v", containerName, err)
+			return err
+		}
+	}
+}
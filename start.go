@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	"lumper/container"
+)
+
+var startCommand = cli.Command{
+	Name:   "start",
+	Usage:  "Start a stopped container",
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("missing container name")
+		}
+		containerName := context.Args().Get(0)
+		startStoppedContainer(containerName)
+		return nil
+	},
+}
+
+// 根据保存的容器信息重新启动已停止的容器
+func startStoppedContainer(containerName string) {
+	// 手动启动后重新按重启策略监控
+	_, err := updateContainerInfo(containerName, func(containerInfo *container.ContainerInfo) error {
+		if containerInfo.Status == container.RUNNING || containerInfo.Status == container.PAUSED || containerInfo.Status == container.RESTARTING {
+			return fmt.Errorf("container is already running")
+		}
+		if len(containerInfo.Args) == 0 {
+			return fmt.Errorf("container has no command to start")
+		}
+		containerInfo.ManuallyStopped = false
+		containerInfo.RestartCount = 0
+		return nil
+	})
+	if err != nil {
+		log.Errorf("start container %s error %v", containerName, err)
+		return
+	}
+	// 由 shim 重新挂载工作空间、加入 Cgroup 和连接网络
+	if err := startShim(containerName); err != nil {
+		log.Errorf("start container %s error %v", containerName, err)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+	"io/ioutil"
+	"lumper/container"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 无法得知容器真实退出码时记录的退出码
+const unknownExitCode = 255
+
+// 锁定容器信息目录，shim 和其他命令读取、修改并记录容器信息期间都要持有，避免互相覆盖
+func lockContainer(containerName string) (func(), error) {
+	dirUrl := fmt.Sprintf(container.DefaultInfoLocation, containerName)
+	dir, err := os.Open(dirUrl)
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.Flock(int(dir.Fd()), unix.LOCK_EX); err != nil {
+		dir.Close()
+		return nil, fmt.Errorf("lock dir %s error %v", dirUrl, err)
+	}
+	return func() {
+		unix.Flock(int(dir.Fd()), unix.LOCK_UN)
+		dir.Close()
+	}, nil
+}
+
+// 读取容器信息，并校验记录的状态与实际进程是否一致
+func loadContainerInfo(containerName string) (*container.ContainerInfo, error) {
+	unlock, err := lockContainer(containerName)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return loadContainerInfoLocked(containerName)
+}
+
+// 在持有容器锁时读取并校验容器信息
+func loadContainerInfoLocked(containerName string) (*container.ContainerInfo, error) {
+	containerInfo, err := getContainerInfoByName(containerName)
+	if err != nil {
+		return nil, err
+	}
+	reconcileContainerLocked(containerInfo)
+	return containerInfo, nil
+}
+
+// 在容器锁内读取容器信息并交给 update 修改，update 返回错误时不记录
+func updateContainerInfo(containerName string, update func(*container.ContainerInfo) error) (*container.ContainerInfo, error) {
+	unlock, err := lockContainer(containerName)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	containerInfo, err := loadContainerInfoLocked(containerName)
+	if err != nil {
+		return nil, err
+	}
+	if err := update(containerInfo); err != nil {
+		return containerInfo, err
+	}
+	if _, err := recordContainerInfo(containerInfo); err != nil {
+		return nil, err
+	}
+	return containerInfo, nil
+}
+
+// 校验列表中读取的容器信息，需要修正时在容器锁内重新读取
+func reconcileContainer(containerInfo *container.ContainerInfo) {
+	if !isContainerActive(containerInfo) {
+		return
+	}
+	latest, err := loadContainerInfo(containerInfo.Name)
+	if err != nil {
+		return
+	}
+	*containerInfo = *latest
+}
+
+// 容器进程已经退出但没有监控进程记录时，将容器状态修正为已退出并释放资源
+func reconcileContainerLocked(containerInfo *container.ContainerInfo) {
+	if !isContainerActive(containerInfo) {
+		return
+	}
+	if (containerInfo.Status == container.RUNNING || containerInfo.Status == container.PAUSED) && isContainerProcessAlive(containerInfo) {
+		return
+	}
+	// 监控进程存在时由其负责记录退出状态
+	if isSupervisorAlive(containerInfo) {
+		return
+	}
+	// 负责启动的进程已经退出，容器没有启动
+	if containerInfo.Status == container.CREATED {
+		log.Warnf("container %s was never started, mark it as exited", containerInfo.Name)
+		containerInfo.ExitCode = unknownExitCode
+		containerInfo.FinishedAt = time.Now()
+		containerInfo.Status = container.EXIT
+		if _, err := recordContainerInfo(containerInfo); err != nil {
+			log.Errorf("record container %s info error %v", containerInfo.Name, err)
+		}
+		return
+	}
+	log.Warnf("container %s process %s is gone, mark it as exited", containerInfo.Name, containerInfo.Pid)
+	containerInfo.Pid = ""
+	containerInfo.ExitCode = unknownExitCode
+	containerInfo.FinishedAt = time.Now()
+	if containerInfo.ManuallyStopped {
+		containerInfo.Status = container.STOP
+	} else {
+		containerInfo.Status = container.EXIT
+	}
+	disconnectContainerNetwork(containerInfo)
+	if _, err := recordContainerInfo(containerInfo); err != nil {
+		log.Errorf("record container %s info error %v", containerInfo.Name, err)
+	}
+	logContainerEvent("die", containerInfo, map[string]string{"exitCode": strconv.Itoa(unknownExitCode)})
+}
+
+// 判断记录的 PID 是否仍是容器的 init 进程，通过进程启动时间排除 PID 被复用的情况
+func isContainerProcessAlive(containerInfo *container.ContainerInfo) bool {
+	pid, err := strconv.Atoi(strings.TrimSpace(containerInfo.Pid))
+	if err != nil {
+		return false
+	}
+	stat, err := getProcessStat(pid)
+	if err != nil || stat.State == "Z" {
+		return false
+	}
+	return containerInfo.PidStartTime == 0 || containerInfo.PidStartTime == stat.StartTime
+}
+
+// 判断监控容器的 shim 或前台运行的 lumper 进程是否存在
+func isSupervisorAlive(containerInfo *container.ContainerInfo) bool {
+	if containerInfo.ShimPid == "" {
+		return false
+	}
+	exe, err := os.Readlink(fmt.Sprintf("/proc/%s/exe", containerInfo.ShimPid))
+	if err != nil {
+		return false
+	}
+	self, err := os.Readlink("/proc/self/exe")
+	if err != nil {
+		return false
+	}
+	return exe == self
+}
+
+// /proc/<pid>/stat 中的进程信息
+type processStat struct {
+	State     string // 进程状态，Z 为僵尸进程
+	Ppid      int    // 父进程 PID
+	Utime     uint64 // 用户态 CPU 时间，单位为 clock tick
+	Stime     uint64 // 内核态 CPU 时间，单位为 clock tick
+	StartTime uint64 // 进程启动时间
+}
+
+// 从 /proc/<pid>/stat 中读取进程信息
+func getProcessStat(pid int) (*processStat, error) {
+	statPath := fmt.Sprintf("/proc/%d/stat", pid)
+	contentBytes, err := ioutil.ReadFile(statPath)
+	if err != nil {
+		return nil, err
+	}
+	// 第 2 列进程名可能包含空格，从最后一个 ')' 之后开始解析，第 3 列为状态，第 4 列为父进程 PID，
+	// 第 14、15 列为用户态和内核态 CPU 时间，第 22 列为启动时间
+	content := string(contentBytes)
+	fields := strings.Fields(content[strings.LastIndex(content, ")")+1:])
+	if len(fields) < 20 {
+		return nil, fmt.Errorf("invalid stat file %s", statPath)
+	}
+	startTime, err := strconv.ParseUint(fields[19], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	ppid, _ := strconv.Atoi(fields[1])
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	return &processStat{
+		State:     fields[0],
+		Ppid:      ppid,
+		Utime:     utime,
+		Stime:     stime,
+		StartTime: startTime,
+	}, nil
+}
+
+// 读取进程启动时间
+func getProcessStartTime(pid int) (uint64, error) {
+	stat, err := getProcessStat(pid)
+	if err != nil {
+		return 0, err
+	}
+	return stat.StartTime, nil
+}
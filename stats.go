@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	"golang.org/x/sys/unix"
+	"lumper/cgroups"
+	"lumper/container"
+	"lumper/network"
+	"os"
+	"text/tabwriter"
+	"text/template"
+	"time"
+)
+
+// 刷新资源使用情况的时间间隔
+const statsInterval = time.Second
+
+var statsCommand = cli.Command{
+	Name:   "stats",
+	Usage:  "Display a live stream of container resource usage",
+	Flags:  []cli.Flag{
+		cli.BoolFlag{
+			Name:  "all, a",
+			Usage: "show all containers, default only running",
+		},
+		cli.BoolFlag{
+			Name:  "no-stream",
+			Usage: "print the first result and exit",
+		},
+		cli.StringFlag{
+			Name:  "format",
+			Value: "table",
+			Usage: "output format, table|json|Go template",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		return showContainerStats(context.Args(), context.Bool("all"), context.Bool("no-stream"), context.String("format"))
+	},
+}
+
+// 容器的资源使用情况
+type containerStats struct {
+	ID            string  `json:"id"`            // 容器 ID
+	Name          string  `json:"name"`          // 容器名
+	CPUPercent    float64 `json:"cpuPercent"`    // CPU 使用率，100% 为占满一个 CPU
+	MemoryUsage   uint64  `json:"memoryUsage"`   // 内存使用量
+	MemoryLimit   uint64  `json:"memoryLimit"`   // 内存限制，不限制时为宿主机内存
+	MemoryPercent float64 `json:"memoryPercent"` // 内存使用率
+	MemoryPeak    uint64  `json:"memoryPeak"`    // 内存使用峰值
+	NetRx         uint64  `json:"netRx"`         // 网络接收字节数
+	NetTx         uint64  `json:"netTx"`         // 网络发送字节数
+	BlockRead     uint64  `json:"blockRead"`     // 块设备读取字节数
+	BlockWrite    uint64  `json:"blockWrite"`    // 块设备写入字节数
+	Pids          uint64  `json:"pids"`          // 进程数
+}
+
+// 上一次采样的 CPU 时间，用于计算 CPU 使用率
+type cpuSample struct {
+	usage uint64
+	time  time.Time
+}
+
+// 持续刷新容器资源使用情况，--no-stream 时只输出一次
+func showContainerStats(names []string, all, noStream bool, format string) error {
+	var tmpl *template.Template
+	if format != "table" && format != "" && format != "json" {
+		var err error
+		if tmpl, err = template.New("stats").Funcs(templateFuncs).Parse(format); err != nil {
+			return fmt.Errorf("parse format error %v", err)
+		}
+	}
+	network.Init()
+	samples := map[string]cpuSample{}
+	// 先采样一次，下一次采样时才能计算 CPU 使用率
+	collectContainerStats(names, all, samples)
+	for {
+		time.Sleep(statsInterval)
+		stats := collectContainerStats(names, all, samples)
+		switch {
+		case tmpl != nil:
+			for _, item := range stats {
+				if err := tmpl.Execute(os.Stdout, item); err != nil {
+					return fmt.Errorf("execute format error %v", err)
+				}
+				fmt.Println()
+			}
+		case format == "json":
+			for _, item := range stats {
+				jsonBytes, err := json.Marshal(item)
+				if err != nil {
+					return fmt.Errorf("json marshal error %v", err)
+				}
+				fmt.Println(string(jsonBytes))
+			}
+		default:
+			// 清屏后重新打印表格
+			if !noStream {
+				fmt.Print("\033[2J\033[H")
+			}
+			if err := printStatsTable(stats); err != nil {
+				return err
+			}
+		}
+		if noStream {
+			return nil
+		}
+	}
+}
+
+// 获取指定容器或所有运行中容器的资源使用情况
+func collectContainerStats(names []string, all bool, samples map[string]cpuSample) []*containerStats {
+	var containers []*container.ContainerInfo
+	if len(names) > 0 {
+		for _, name := range names {
+			containerInfo, err := loadContainerInfo(name)
+			if err != nil {
+				log.Errorf("get container %s info error %v", name, err)
+				continue
+			}
+			containers = append(containers, containerInfo)
+		}
+	} else {
+		infos, err := getAllContainerInfo()
+		if err != nil {
+			return nil
+		}
+		for _, item := range infos {
+			reconcileContainer(item)
+			if all || item.Status == container.RUNNING || item.Status == container.PAUSED {
+				containers = append(containers, item)
+			}
+		}
+	}
+	var stats []*containerStats
+	for _, item := range containers {
+		stats = append(stats, getContainerStats(item, samples))
+	}
+	return stats
+}
+
+// 读取容器 Cgroup 和网络端点的统计信息，没有运行的容器统计值为 0
+func getContainerStats(containerInfo *container.ContainerInfo, samples map[string]cpuSample) *containerStats {
+	stats := &containerStats{
+		ID:   containerInfo.Id,
+		Name: containerInfo.Name,
+	}
+	if containerInfo.Status != container.RUNNING && containerInfo.Status != container.PAUSED {
+		delete(samples, containerInfo.Id)
+		return stats
+	}
+	cgroupStats, err := cgroups.NewCgroupManager(containerInfo.GetCgroupPath()).GetStats()
+	if err != nil {
+		log.Errorf("get container %s cgroup stats error %v", containerInfo.Name, err)
+		return stats
+	}
+	now := time.Now()
+	if last, ok := samples[containerInfo.Id]; ok && cgroupStats.CpuUsage >= last.usage {
+		stats.CPUPercent = float64(cgroupStats.CpuUsage - last.usage) / float64(now.Sub(last.time).Nanoseconds()) * 100
+	}
+	samples[containerInfo.Id] = cpuSample{usage: cgroupStats.CpuUsage, time: now}
+
+	stats.MemoryUsage = cgroupStats.MemoryUsage
+	stats.MemoryPeak = cgroupStats.MemoryPeak
+	stats.MemoryLimit = cgroupStats.MemoryLimit
+	// 不限制内存时以宿主机内存作为上限
+	var info unix.Sysinfo_t
+	if err := unix.Sysinfo(&info); err == nil {
+		hostMemory := uint64(info.Totalram) * uint64(info.Unit)
+		if stats.MemoryLimit == 0 || stats.MemoryLimit > hostMemory {
+			stats.MemoryLimit = hostMemory
+		}
+	}
+	if stats.MemoryLimit > 0 {
+		stats.MemoryPercent = float64(stats.MemoryUsage) / float64(stats.MemoryLimit) * 100
+	}
+	stats.BlockRead = cgroupStats.BlkioRead
+	stats.BlockWrite = cgroupStats.BlkioWrite
+	stats.Pids = cgroupStats.PidsCurrent
+
+	if containerInfo.Network != "" {
+		if rx, tx, err := network.GetEndpointStats(containerInfo); err == nil {
+			stats.NetRx, stats.NetTx = rx, tx
+		}
+	}
+	return stats
+}
+
+func printStatsTable(stats []*containerStats) error {
+	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
+	fmt.Fprint(w, "ID\tNAME\tCPU %\tMEM USAGE / LIMIT\tMEM %\tNET I/O\tBLOCK I/O\tPIDS\n")
+	for _, item := range stats {
+		fmt.Fprintf(w, "%s\t%s\t%.2f%%\t%s / %s\t%.2f%%\t%s / %s\t%s / %s\t%d\n",
+			item.ID,
+			item.Name,
+			item.CPUPercent,
+			humanSize(item.MemoryUsage),
+			humanSize(item.MemoryLimit),
+			item.MemoryPercent,
+			humanSize(item.NetRx),
+			humanSize(item.NetTx),
+			humanSize(item.BlockRead),
+			humanSize(item.BlockWrite),
+			item.Pids)
+	}
+	if err := w.Flush(); err != nil {
+		log.Errorf("flush container stats error %v", err)
+		return err
+	}
+	return nil
+}
+
+// 将字节数转换成可读的大小，如 1.5MiB
+func humanSize(size uint64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	value := float64(size)
+	i := 0
+	for value >= 1024 && i < len(units) - 1 {
+		value /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%dB", size)
+	}
+	return fmt.Sprintf("%.2f%s", value, units[i])
+}
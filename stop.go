@@ -8,72 +8,99 @@ import (
 	"golang.org/x/sys/unix"
 	"io/ioutil"
 	"lumper/container"
-	"strconv"
+	"time"
+)
+
+const (
+	// 轮询容器状态的时间间隔
+	stopPollInterval = 100 * time.Millisecond
+	// 发送 SIGKILL 后等待容器退出的时间
+	stopKillTimeout = 5 * time.Second
 )
 
 var stopCommand = cli.Command{
 	Name:   "stop",
 	Usage:  "Stop a container",
+	Flags:  []cli.Flag{
+		cli.IntFlag{
+			Name:  "time, t",
+			Value: 10,
+			Usage: "seconds to wait for stop before killing it",
+		},
+	},
 	Action: func(context *cli.Context) error {
 		if len(context.Args()) < 1 {
 			return fmt.Errorf("missing container name")
 		}
 		containerName := context.Args().Get(0)
-		stopContainer(containerName)
+		stopContainer(containerName, time.Duration(context.Int("time")) * time.Second)
 		return nil
 	},
 }
 
-func stopContainer(containerName string)  {
-	// 根据容器名获取主进程 PID
-	pid, err := getContainerPidByName(containerName)
+// 停止容器，先发送 SIGTERM 信号，超时后发送 SIGKILL 信号
+func stopContainer(containerName string, timeout time.Duration)  {
+	// 标记为手动停止，监控进程不再重启容器
+	containerInfo, err := updateContainerInfo(containerName, func(containerInfo *container.ContainerInfo) error {
+		containerInfo.ManuallyStopped = true
+		if containerInfo.Status == container.RESTARTING {
+			containerInfo.Status = container.STOP
+		}
+		return nil
+	})
 	if err != nil {
-		log.Errorf("get container pid by name %s error %v", containerName, err)
+		log.Errorf("record container %s info error %v", containerName, err)
 		return
 	}
-	// 将 string 类型的 PID 转换成 int 类型
-	pidInt, err := strconv.Atoi(pid)
-	if err != nil {
-		log.Errorf("convert pid from string to int error %v", err)
+	if containerInfo.Status != container.RUNNING && containerInfo.Status != container.PAUSED {
 		return
 	}
-	// 调用 kill 发送 SIGTERM 信号给进程，从而杀掉容器主进程
-	if err := unix.Kill(pidInt, unix.SIGTERM); err != nil {
-		log.Errorf("stop container %s error %v", containerName, err)
+	// 冻结的进程无法处理信号，先解冻容器
+	if containerInfo.Status == container.PAUSED {
+		if _, err := thawContainer(containerName); err != nil {
+			log.Errorf("unpause container %s error %v", containerName, err)
+			return
+		}
+	}
+	// 发送 SIGTERM 信号给容器主进程，等待容器主进程退出
+	if err := killContainer(containerName, unix.SIGTERM); err != nil {
+		if err != errContainerNotRunning {
+			log.Errorf("stop container %s error %v", containerName, err)
+		}
 		return
 	}
-	// 根据容器配置文件获取信息，并转换成容器信息对象
-	containerInfo, err := getContainerInfoByName(containerName)
-	if err != nil {
-		log.Errorf("get container %s info error %v", containerName, err)
+	if waitContainerStop(containerName, timeout) {
+		logContainerEvent("stop", containerInfo, nil)
 		return
 	}
-	containerInfo.Status = container.STOP
-	containerInfo.Pid = " "
-	newContentBytes, err := json.Marshal(containerInfo)
-	if err != nil {
-		log.Errorf("json marshal %s error %v", containerName, err)
+	// 超时后发送 SIGKILL 信号强制杀掉容器主进程
+	if timeout > 0 {
+		log.Warnf("container %s did not stop in %v, killing it", containerName, timeout)
+	}
+	if err := killContainer(containerName, unix.SIGKILL); err != nil && err != errContainerNotRunning {
+		log.Errorf("kill container %s error %v", containerName, err)
 		return
 	}
-	dirUrl := fmt.Sprintf(container.DefaultInfoLocation, containerName)
-	configFilePath := dirUrl + container.ConfigName
-	if err := ioutil.WriteFile(configFilePath, newContentBytes, 0622); err != nil {
-		log.Errorf("write file %s error %v", configFilePath, err)
+	if !waitContainerStop(containerName, stopKillTimeout) {
+		log.Errorf("container %s did not stop after SIGKILL", containerName)
+		return
 	}
+	logContainerEvent("stop", containerInfo, nil)
 }
 
-func getContainerPidByName(containerName string) (string, error) {
-	dirUrl:= fmt.Sprintf(container.DefaultInfoLocation, containerName)
-	configFilePath := dirUrl + container.ConfigName
-	contentBytes, err := ioutil.ReadFile(configFilePath)
-	if err != nil {
-		return "", err
-	}
-	var containerInfo container.ContainerInfo
-	if err := json.Unmarshal(contentBytes, &containerInfo); err != nil {
-		return "", err
+// 等待容器退出，退出状态由监控进程记录，监控进程不存在时在读取状态时修正
+func waitContainerStop(containerName string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		containerInfo, err := loadContainerInfo(containerName)
+		if err != nil || (containerInfo.Status != container.RUNNING && containerInfo.Status != container.PAUSED) {
+			return true
+		}
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		time.Sleep(stopPollInterval)
 	}
-	return containerInfo.Pid, nil
 }
 
 // 根据容器名获取容器对象
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	"golang.org/x/sys/unix"
+	"io/ioutil"
+	"lumper/cgroups"
+	"lumper/container"
+	"lumper/network"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var systemCommand = cli.Command{
+	Name:        "system",
+	Usage:       "Manage lumper",
+	Subcommands: []cli.Command {
+		{
+			Name:  "prune",
+			Usage: "Remove resources leaked by crashed containers",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "only list the resources that would be removed",
+				},
+				cli.BoolFlag{
+					Name:  "volumes",
+					Usage: "also remove anonymous volumes not used by any container",
+				},
+			},
+			Action: func(context *cli.Context) error {
+				return pruneSystem(context.Bool("dry-run"), context.Bool("volumes"))
+			},
+		},
+	},
+}
+
+// 对照容器配置和宿主机上实际存在的资源，清理没有被任何容器引用的资源
+func pruneSystem(dryRun, pruneVolumes bool) error {
+	containers, err := getAllContainerInfo()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, containerInfo := range containers {
+		reconcileContainer(containerInfo)
+	}
+	action := "Deleted"
+	if dryRun {
+		action = "Would delete"
+	}
+	report := func(kind string, items []string) {
+		for _, item := range items {
+			fmt.Printf("%s %s: %s\n", action, kind, item)
+		}
+	}
+
+	report("overlay2", pruneWorkSpaces(containers, dryRun))
+	if pruneVolumes {
+		report("volume", pruneAnonymousVolumes(containers, dryRun))
+	}
+	report("cgroup", pruneCgroups(containers, dryRun))
+
+	// 占用或正在申请网络资源的容器，启动和重启中的容器以及监控进程存在的容器随时可能连接网络
+	var networkContainers []*container.ContainerInfo
+	for _, containerInfo := range containers {
+		if containerInfo.Network == "" {
+			continue
+		}
+		if containerInfo.NetworkConnected || containerInfo.Status == container.CREATED || containerInfo.Status == container.RESTARTING || isSupervisorAlive(containerInfo) {
+			networkContainers = append(networkContainers, containerInfo)
+		}
+	}
+	network.Init()
+	endpoints, err := network.PruneEndpoints(networkContainers, dryRun)
+	if err != nil {
+		log.Errorf("prune endpoints error %v", err)
+	}
+	report("veth", endpoints)
+	rules, err := network.PruneIPTables(networkContainers, dryRun)
+	if err != nil {
+		log.Errorf("prune iptables error %v", err)
+	}
+	report("iptables rule", rules)
+	ips, err := network.PruneIPAllocations(networkContainers, dryRun)
+	if err != nil {
+		log.Errorf("prune ip allocations error %v", err)
+	}
+	report("ip allocation", ips)
+	return nil
+}
+
+// 清理没有容器配置的 overlay2 工作空间
+func pruneWorkSpaces(containers []*container.ContainerInfo, dryRun bool) []string {
+	inUse := map[string]bool{"images": true}
+	for _, containerInfo := range containers {
+		inUse[containerInfo.Name] = true
+	}
+	overlayRoot := filepath.Clean(fmt.Sprintf(container.Overlay2Location, ""))
+	files, err := ioutil.ReadDir(overlayRoot)
+	if err != nil {
+		return nil
+	}
+	var pruned []string
+	for _, file := range files {
+		if !file.IsDir() || inUse[file.Name()] {
+			continue
+		}
+		workSpace := filepath.Join(overlayRoot, file.Name())
+		pruned = append(pruned, workSpace)
+		if dryRun {
+			continue
+		}
+		// 先卸载数据卷和 overlay2 挂载点，再删除目录，避免删除数据卷中的数据
+		unmounted := true
+		for _, mountPoint := range container.GetMountPoints(workSpace) {
+			if err := unix.Unmount(mountPoint, unix.MNT_DETACH); err != nil {
+				log.Errorf("umount %s error %v", mountPoint, err)
+				unmounted = false
+			}
+		}
+		if !unmounted {
+			continue
+		}
+		if err := os.RemoveAll(workSpace); err != nil {
+			log.Errorf("remove dir %s error %v", workSpace, err)
+		}
+	}
+	return pruned
+}
+
+// 清理没有被容器使用的匿名数据卷
+func pruneAnonymousVolumes(containers []*container.ContainerInfo, dryRun bool) []string {
+	inUse := map[string]bool{}
+	for _, containerInfo := range containers {
+		if volumeUrls := container.ParseVolume(containerInfo.Volume); volumeUrls != nil {
+			inUse[filepath.Clean(volumeUrls[0])] = true
+		}
+	}
+	volumeRoot := filepath.Clean(fmt.Sprintf(container.VolumeLocation, ""))
+	files, err := ioutil.ReadDir(volumeRoot)
+	if err != nil {
+		return nil
+	}
+	var pruned []string
+	for _, file := range files {
+		volume := filepath.Join(volumeRoot, file.Name())
+		if inUse[volume] {
+			continue
+		}
+		pruned = append(pruned, volume)
+		if dryRun {
+			continue
+		}
+		if err := os.RemoveAll(volume); err != nil {
+			log.Errorf("remove dir %s error %v", volume, err)
+		}
+	}
+	return pruned
+}
+
+// 清理没有被容器引用且不包含进程的 Cgroup
+func pruneCgroups(containers []*container.ContainerInfo, dryRun bool) []string {
+	inUse := map[string]bool{}
+	parents := map[string]bool{container.DefaultCgroupParent: true}
+	for _, containerInfo := range containers {
+		inUse[containerInfo.GetCgroupPath()] = true
+		if containerInfo.CgroupPath != "" {
+			parents[filepath.Dir(containerInfo.CgroupPath)] = true
+		}
+	}
+	// 候选的 Cgroup 为旧版本共用的 Cgroup 和各个父 Cgroup 下的子 Cgroup
+	candidates := map[string]bool{container.LegacyCgroupPath: true}
+	for parent := range parents {
+		for _, parentPath := range cgroups.NewCgroupManager(parent).Paths() {
+			files, err := ioutil.ReadDir(parentPath)
+			if err != nil {
+				continue
+			}
+			for _, file := range files {
+				if file.IsDir() {
+					candidates[filepath.Join(parent, file.Name())] = true
+				}
+			}
+		}
+	}
+
+	var pruned []string
+	for candidate := range candidates {
+		if inUse[candidate] {
+			continue
+		}
+		cgroupManager := cgroups.NewCgroupManager(candidate)
+		empty := true
+		var paths []string
+		for _, cgroupPath := range cgroupManager.Paths() {
+			if exist, _ := container.PathExists(cgroupPath); !exist {
+				continue
+			}
+			tasks, err := ioutil.ReadFile(filepath.Join(cgroupPath, "cgroup.procs"))
+			if err != nil || strings.TrimSpace(string(tasks)) != "" {
+				empty = false
+				break
+			}
+			paths = append(paths, cgroupPath)
+		}
+		if !empty || len(paths) == 0 {
+			continue
+		}
+		pruned = append(pruned, paths...)
+		if !dryRun {
+			cgroupManager.Destroy()
+		}
+	}
+	return pruned
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	"io/ioutil"
+	"lumper/cgroups"
+	"lumper/container"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	// /proc/<pid>/stat 中 CPU 时间的单位，Linux 上固定为 100
+	clockTicks = 100
+	// 默认显示的列
+	defaultTopColumns = "user,pid,nspid,ppid,stat,time,rss,cmd"
+)
+
+var topCommand = cli.Command{
+	Name:   "top",
+	Usage:  "Display the running processes of a container",
+	Flags:  []cli.Flag{
+		cli.StringFlag{
+			Name:  "o",
+			Value: defaultTopColumns,
+			Usage: "comma separated columns, user|uid|pid|nspid|ppid|stat|time|rss|vsz|comm|cmd",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("missing container name")
+		}
+		return topContainer(context.Args().Get(0), context.String("o"))
+	},
+}
+
+// 容器内进程的信息
+type processInfo struct {
+	Pid     int
+	NsPid   string // 容器 PID namespace 中的 PID
+	Ppid    int
+	Uid     string
+	User    string
+	State   string
+	CpuTime time.Duration
+	Rss     uint64 // 单位为 KiB
+	Vsz     uint64 // 单位为 KiB
+	Comm    string
+	Cmdline string
+}
+
+// 每一列的标题和取值方法
+var topColumns = map[string]struct {
+	header string
+	value  func(p *processInfo) string
+}{
+	"user":  {"USER", func(p *processInfo) string { return p.User }},
+	"uid":   {"UID", func(p *processInfo) string { return p.Uid }},
+	"pid":   {"PID", func(p *processInfo) string { return strconv.Itoa(p.Pid) }},
+	"nspid": {"NSPID", func(p *processInfo) string { return p.NsPid }},
+	"ppid":  {"PPID", func(p *processInfo) string { return strconv.Itoa(p.Ppid) }},
+	"stat":  {"STAT", func(p *processInfo) string { return p.State }},
+	"time":  {"TIME", func(p *processInfo) string { return formatCpuTime(p.CpuTime) }},
+	"rss":   {"RSS", func(p *processInfo) string { return strconv.FormatUint(p.Rss, 10) }},
+	"vsz":   {"VSZ", func(p *processInfo) string { return strconv.FormatUint(p.Vsz, 10) }},
+	"comm":  {"COMMAND", func(p *processInfo) string { return p.Comm }},
+	"cmd":   {"CMD", func(p *processInfo) string { return p.Cmdline }},
+}
+
+// 列出容器 Cgroup 中的所有进程
+func topContainer(containerName, columns string) error {
+	var fields []string
+	for _, column := range strings.Split(columns, ",") {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if _, ok := topColumns[column]; !ok {
+			return fmt.Errorf("unknown column %s", column)
+		}
+		fields = append(fields, column)
+	}
+	containerInfo, err := loadContainerInfo(containerName)
+	if err != nil {
+		return fmt.Errorf("get container %s info error %v", containerName, err)
+	}
+	if containerInfo.Status != container.RUNNING && containerInfo.Status != container.PAUSED {
+		return fmt.Errorf("container %s is not running", containerName)
+	}
+	pids, err := cgroups.NewCgroupManager(containerInfo.GetCgroupPath()).GetPids()
+	if err != nil {
+		return fmt.Errorf("get container %s processes error %v", containerName, err)
+	}
+	sort.Ints(pids)
+	// 用户名以容器内的 /etc/passwd 为准，同一个 UID 以第一个用户名为准
+	users := map[int]string{}
+	for _, entry := range container.ReadPasswdFile(path.Join(fmt.Sprintf(container.Overlay2Location, containerName), "merged", "etc", "passwd")) {
+		if _, exist := users[entry.Uid]; !exist {
+			users[entry.Uid] = entry.Name
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 8, 1, 3, ' ', 0)
+	var headers []string
+	for _, field := range fields {
+		headers = append(headers, topColumns[field].header)
+	}
+	fmt.Fprintln(w, strings.Join(headers, "\t"))
+	for _, pid := range pids {
+		process, err := getProcessInfo(pid)
+		if err != nil {
+			// 读取期间进程可能已经退出
+			log.Debugf("get process %d info error %v", pid, err)
+			continue
+		}
+		if uid, err := strconv.Atoi(process.Uid); err == nil && users[uid] != "" {
+			process.User = users[uid]
+		} else {
+			process.User = process.Uid
+		}
+		var values []string
+		for _, field := range fields {
+			values = append(values, topColumns[field].value(process))
+		}
+		fmt.Fprintln(w, strings.Join(values, "\t"))
+	}
+	return w.Flush()
+}
+
+// 从 /proc/<pid> 中读取进程信息
+func getProcessInfo(pid int) (*processInfo, error) {
+	stat, err := getProcessStat(pid)
+	if err != nil {
+		return nil, err
+	}
+	process := &processInfo{
+		Pid:     pid,
+		Ppid:    stat.Ppid,
+		State:   stat.State,
+		CpuTime: time.Duration(stat.Utime + stat.Stime) * time.Second / clockTicks,
+	}
+	status, err := os.Open(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return nil, err
+	}
+	defer status.Close()
+	scanner := bufio.NewScanner(status)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// 进程名中可能包含空格，取冒号后的整个值
+		if strings.HasPrefix(line, "Name:") {
+			process.Comm = strings.TrimPrefix(strings.TrimPrefix(line, "Name:"), "\t")
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "Uid:":
+			// 依次为 real、effective、saved 和 filesystem UID，与 ps 一样显示 effective UID
+			if len(fields) > 2 {
+				process.Uid = fields[2]
+			}
+		case "NSpid:":
+			// 最后一个为进程在最内层 PID namespace 中的 PID
+			process.NsPid = fields[len(fields)-1]
+		case "VmRSS:":
+			process.Rss, _ = strconv.ParseUint(fields[1], 10, 64)
+		case "VmSize:":
+			process.Vsz, _ = strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+	// cmdline 中的参数以 \0 分隔，内核线程和僵尸进程的 cmdline 为空
+	cmdline, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err == nil && len(cmdline) > 0 {
+		process.Cmdline = strings.TrimSpace(strings.Replace(string(cmdline), "\x00", " ", -1))
+	} else {
+		process.Cmdline = "[" + process.Comm + "]"
+	}
+	return process, nil
+}
+
+// 与 ps 一样将 CPU 时间显示为 [DD-]HH:MM:SS
+func formatCpuTime(d time.Duration) string {
+	seconds := int(d.Seconds())
+	days := seconds / 86400
+	text := fmt.Sprintf("%02d:%02d:%02d", seconds % 86400 / 3600, seconds % 3600 / 60, seconds % 60)
+	if days > 0 {
+		return fmt.Sprintf("%d-%s", days, text)
+	}
+	return text
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	"lumper/container"
+	"time"
+)
+
+// 轮询容器状态的时间间隔
+const waitPollInterval = 100 * time.Millisecond
+
+var waitCommand = cli.Command{
+	Name:   "wait",
+	Usage:  "Block until containers stop, then print their exit codes",
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("missing container name")
+		}
+		// lumper 的退出码为最后一个容器的退出码
+		exitCode := 0
+		for _, containerName := range context.Args() {
+			code, err := waitContainerExit(containerName)
+			if err != nil {
+				log.Errorf("wait container %s error %v", containerName, err)
+				exitCode = 1
+				continue
+			}
+			fmt.Println(code)
+			exitCode = code
+		}
+		if exitCode != 0 {
+			return cli.NewExitError("", exitCode)
+		}
+		return nil
+	},
+}
+
+// 等待容器退出并返回退出码，退出状态由监控容器进程的 shim 记录
+func waitContainerExit(containerName string) (int, error) {
+	containerInfo, err := loadContainerInfo(containerName)
+	if err != nil {
+		return 0, err
+	}
+	if !isContainerActive(containerInfo) {
+		return containerInfo.ExitCode, nil
+	}
+	// 以退出时间的变化判断容器退出，避免错过重启前短暂的退出状态
+	finishedAt := containerInfo.FinishedAt
+	for {
+		time.Sleep(waitPollInterval)
+		containerInfo, err = loadContainerInfo(containerName)
+		if err != nil {
+			return 0, fmt.Errorf("container %s removed while waiting", containerName)
+		}
+		if !containerInfo.FinishedAt.Equal(finishedAt) {
+			return containerInfo.ExitCode, nil
+		}
+	}
+}
+
+// 容器是否处于创建、运行或重启中
+func isContainerActive(containerInfo *container.ContainerInfo) bool {
+	switch containerInfo.Status {
+	case container.CREATED, container.RUNNING, container.PAUSED, container.RESTARTING:
+		return true
+	}
+	return false
+}